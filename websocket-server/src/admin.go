@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// adminAPI lets operators look at and manage a running server over http
+// every request needs the admin token as a bearer token
+type adminAPI struct {
+	rooms *roomRegistry
+	token string
+}
+
+// roomSummary is a room as listed by the admin api
+type roomSummary struct {
+	Name    string `json:"name"`
+	Players int    `json:"players"`
+	Paused  bool   `json:"paused"`
+	Mode    string `json:"mode"`
+	Phase   string `json:"phase"`
+}
+
+// playerDetails is everything about a player, as inspected by the admin api
+type playerDetails struct {
+	Entity    *entity         `json:"entity"`
+	Inventory []inventorySlot `json:"inventory"`
+}
+
+// position is where to teleport a player to
+type position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// healing is how much to heal a player, 0 for all the way
+type healing struct {
+	Amount int `json:"amount"`
+}
+
+type announcement struct {
+	Message string `json:"message"`
+}
+
+// adminEvents are the events the admin api sends rooms, going through them like any other event so replays play them too
+// clients can't send them, they aren't in eventTypes
+// the player they act on is the event's target, and paused rooms still handle them
+var adminEvents = map[string]bool{
+	"adminKick":     true,
+	"adminBan":      true,
+	"adminTeleport": true,
+	"adminHeal":     true,
+	"pause":         true,
+	"resume":        true,
+	"announce":      true,
+}
+
+// kickedMessage is what players removed by an admin are told as their connection closes
+const kickedMessage = "you were removed from the room by an admin"
+
+func (a adminAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/rooms", a.listRooms)
+	mux.HandleFunc("GET /admin/rooms/{room}/players", a.listPlayers)
+	mux.HandleFunc("GET /admin/rooms/{room}/players/{player}", a.inspectPlayer)
+	mux.HandleFunc("POST /admin/rooms/{room}/players/{player}/kick", a.kickPlayer)
+	mux.HandleFunc("POST /admin/rooms/{room}/players/{player}/ban", a.banPlayer)
+	mux.HandleFunc("POST /admin/rooms/{room}/players/{player}/teleport", a.teleportPlayer)
+	mux.HandleFunc("POST /admin/rooms/{room}/players/{player}/heal", a.healPlayer)
+	mux.HandleFunc("POST /admin/rooms/{room}/pause", a.pauseRoom)
+	mux.HandleFunc("POST /admin/rooms/{room}/resume", a.resumeRoom)
+	mux.HandleFunc("POST /admin/broadcast", a.broadcast)
+	return a.authenticate(mux)
+}
+
+// authenticate turns away requests without the admin token
+func (a adminAPI) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Println("admin write:", err)
+	}
+}
+
+// readJSON decodes the request body, replying with an error if it can't
+func readJSON(w http.ResponseWriter, r *http.Request, value any) bool {
+	err := json.NewDecoder(r.Body).Decode(value)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// withRoom runs f with the room in the path locked, replying not found if there is no such room
+func (a adminAPI) withRoom(w http.ResponseWriter, r *http.Request, f func(rm *room)) {
+	rm, ok := a.rooms.lookup(r.PathValue("room"))
+	if !ok {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	f(rm)
+}
+
+// withPlayer runs f with the player in the path, with their room locked
+func (a adminAPI) withPlayer(w http.ResponseWriter, r *http.Request, f func(rm *room, p *entity)) {
+	a.withRoom(w, r, func(rm *room) {
+		p, err := rm.state.getPlayer(r.PathValue("player"))
+		if err != nil {
+			http.Error(w, "player not found", http.StatusNotFound)
+			return
+		}
+		f(rm, p)
+	})
+}
+
+func (a adminAPI) listRooms(w http.ResponseWriter, r *http.Request) {
+	summaries := []roomSummary{}
+	for _, rm := range a.rooms.list() {
+		rm.mu.Lock()
+		s := roomSummary{
+			Name:    rm.name,
+			Players: rm.state.playerCount(),
+			Paused:  rm.state.paused,
+			Mode:    rm.state.Settings.Mode,
+		}
+		if rm.state.Match != nil {
+			s.Phase = rm.state.Match.Phase
+		}
+		rm.mu.Unlock()
+		summaries = append(summaries, s)
+	}
+	writeJSON(w, summaries)
+}
+
+func (a adminAPI) listPlayers(w http.ResponseWriter, r *http.Request) {
+	a.withRoom(w, r, func(rm *room) {
+		writeJSON(w, rm.state.getPlayers())
+	})
+}
+
+func (a adminAPI) inspectPlayer(w http.ResponseWriter, r *http.Request) {
+	a.withPlayer(w, r, func(rm *room, p *entity) {
+		writeJSON(w, playerDetails{Entity: p, Inventory: p.Inventory.Slots})
+	})
+}
+
+func (a adminAPI) kickPlayer(w http.ResponseWriter, r *http.Request) {
+	a.withPlayer(w, r, func(rm *room, p *entity) {
+		rm.adminAction(gameEvent{Type: "adminKick", Data: eventData{Target: p.Actor.Name}})
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// banPlayer kicks the player and stops anyone joining the room with their name again
+func (a adminAPI) banPlayer(w http.ResponseWriter, r *http.Request) {
+	a.withRoom(w, r, func(rm *room) {
+		rm.adminAction(gameEvent{Type: "adminBan", Data: eventData{Target: r.PathValue("player")}})
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (a adminAPI) teleportPlayer(w http.ResponseWriter, r *http.Request) {
+	to := position{}
+	if !readJSON(w, r, &to) {
+		return
+	}
+	a.withPlayer(w, r, func(rm *room, p *entity) {
+		rm.adminAction(gameEvent{Type: "adminTeleport", Data: eventData{player: player{X: to.X, Y: to.Y}, Target: p.Actor.Name}})
+		writeJSON(w, p.toPlayer())
+	})
+}
+
+// healPlayer heals the player, bringing them straight back if they are dead
+func (a adminAPI) healPlayer(w http.ResponseWriter, r *http.Request) {
+	h := healing{}
+	if !readJSON(w, r, &h) {
+		return
+	}
+	a.withPlayer(w, r, func(rm *room, p *entity) {
+		rm.adminAction(gameEvent{Type: "adminHeal", Data: eventData{Count: h.Amount, Target: p.Actor.Name}})
+		writeJSON(w, p.toPlayer())
+	})
+}
+
+func (a adminAPI) pauseRoom(w http.ResponseWriter, r *http.Request) {
+	a.withRoom(w, r, func(rm *room) {
+		rm.adminAction(gameEvent{Type: "pause"})
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (a adminAPI) resumeRoom(w http.ResponseWriter, r *http.Request) {
+	a.withRoom(w, r, func(rm *room) {
+		rm.adminAction(gameEvent{Type: "resume"})
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// adminAction applies an event from the admin api with the room locked,
+// closing the connections of anyone it kicks so their clients stop sending events for a player that is gone
+func (rm *room) adminAction(event gameEvent) {
+	rm.apply(event)
+	if event.Type == "adminKick" || event.Type == "adminBan" {
+		rm.closePlayer(event.Data.Target, kickedMessage)
+	}
+}
+
+// adminBan kicks the player and stops anyone joining the room with their name again
+func (gs *gameState) adminBan(name string) {
+	gs.banned[name] = true
+	gs.removePlayer(name)
+}
+
+func (gs *gameState) adminTeleport(name string, x, y int) {
+	p, err := gs.getPlayer(name)
+	if err != nil {
+		log.Println("cannot find player to teleport")
+		return
+	}
+	p.Position.X = x
+	p.Position.Y = y
+	p.Actor.path = nil
+}
+
+// adminHeal heals the player by amount, all the way if it is 0, bringing them straight back if they are dead
+func (gs *gameState) adminHeal(name string, amount int) {
+	p, err := gs.getPlayer(name)
+	if err != nil {
+		log.Println("cannot find player to heal")
+		return
+	}
+	if p.isDead() {
+		p.Health.Current = 0
+		p.Actor.respawnAt = 0
+	}
+	if amount <= 0 {
+		amount = p.Health.Max
+	}
+	p.Health.Current = min(p.Health.Current+amount, p.Health.Max)
+}
+
+// broadcast sends a message from the server to everyone in every room
+func (a adminAPI) broadcast(w http.ResponseWriter, r *http.Request) {
+	message := announcement{}
+	if !readJSON(w, r, &message) {
+		return
+	}
+	if strings.TrimSpace(message.Message) == "" {
+		http.Error(w, "message is required", http.StatusBadRequest)
+		return
+	}
+	for _, rm := range a.rooms.list() {
+		rm.mu.Lock()
+		rm.adminAction(gameEvent{Type: "announce", Data: eventData{Message: message.Message}})
+		rm.mu.Unlock()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "secret"
+
+// newTestAdmin returns an admin api with a room holding player1 and player2
+func newTestAdmin(t *testing.T) (*httptest.Server, *room) {
+	rooms := newRoomRegistry(newGameState)
+	rm := rooms.get("arena")
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{X: 100, Y: 100, Name: "player1", Facing: "down"}}}, "player1")
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{X: 300, Y: 300, Name: "player2", Facing: "down"}}}, "player2")
+
+	server := httptest.NewServer(adminAPI{rooms: rooms, token: testAdminToken}.handler())
+	t.Cleanup(server.Close)
+	return server, rm
+}
+
+func adminRequest(t *testing.T, server *httptest.Server, method, path, token, body string) *http.Response {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decodeAdmin[T any](t *testing.T, resp *http.Response) T {
+	var decoded T
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	return decoded
+}
+
+func TestAdminAuth(t *testing.T) {
+	server, _ := newTestAdmin(t)
+	for _, tc := range []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "no token", token: "", status: http.StatusUnauthorized},
+		{name: "wrong token", token: "guess", status: http.StatusUnauthorized},
+		{name: "right token", token: testAdminToken, status: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := adminRequest(t, server, "GET", "/admin/rooms", tc.token, "")
+			require.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestAdminListAndInspect(t *testing.T) {
+	server, _ := newTestAdmin(t)
+
+	rooms := decodeAdmin[[]roomSummary](t, adminRequest(t, server, "GET", "/admin/rooms", testAdminToken, ""))
+	require.Len(t, rooms, 1)
+	require.Equal(t, "arena", rooms[0].Name)
+	require.Equal(t, 2, rooms[0].Players)
+	require.False(t, rooms[0].Paused)
+
+	players := decodeAdmin[[]player](t, adminRequest(t, server, "GET", "/admin/rooms/arena/players", testAdminToken, ""))
+	require.Len(t, players, 2)
+
+	details := decodeAdmin[map[string]any](t, adminRequest(t, server, "GET", "/admin/rooms/arena/players/player1", testAdminToken, ""))
+	require.Contains(t, details, "entity")
+	require.Contains(t, details, "inventory")
+
+	resp := adminRequest(t, server, "GET", "/admin/rooms/arena/players/nobody", testAdminToken, "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = adminRequest(t, server, "GET", "/admin/rooms/nowhere/players", testAdminToken, "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAdminKickAndBan(t *testing.T) {
+	server, rm := newTestAdmin(t)
+
+	resp := adminRequest(t, server, "POST", "/admin/rooms/arena/players/player1/kick", testAdminToken, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	_, err := rm.state.getPlayer("player1")
+	require.Error(t, err)
+
+	// kicked players can come back, banned ones can't
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{Name: "player1", Facing: "down"}}}, "player1")
+	_, err = rm.state.getPlayer("player1")
+	require.NoError(t, err)
+
+	resp = adminRequest(t, server, "POST", "/admin/rooms/arena/players/player1/ban", testAdminToken, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{Name: "player1", Facing: "down"}}}, "player1")
+	_, err = rm.state.getPlayer("player1")
+	require.Error(t, err)
+}
+
+func TestBansOutlastTheRoom(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	game := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer game.Close()
+	server := httptest.NewServer(adminAPI{rooms: rooms, token: testAdminToken}.handler())
+	defer server.Close()
+
+	c := dialTestRoom(t, game, "arena")
+	sendTestEvent(t, c, gameEvent{Type: "join", Data: eventData{player: player{Name: "griefer", Facing: "down"}}})
+	resp := adminRequest(t, server, "POST", "/admin/rooms/arena/players/griefer/ban", testAdminToken, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// waiting for the room to empty and close doesn't lift the ban
+	require.Eventually(t, func() bool { return len(rooms.list()) == 0 }, time.Second, 10*time.Millisecond)
+	c = dialTestRoom(t, game, "arena")
+	decoded := sendTestEvent(t, c, gameEvent{Type: "join", Data: eventData{player: player{Name: "griefer", Facing: "down"}}})
+	require.Empty(t, decoded.Players)
+
+	// it is only for that room
+	c = dialTestRoom(t, game, "lobby")
+	decoded = sendTestEvent(t, c, gameEvent{Type: "join", Data: eventData{player: player{Name: "griefer", Facing: "down"}}})
+	require.Len(t, decoded.Players, 1)
+}
+
+func TestAdminTeleportAndHeal(t *testing.T) {
+	server, rm := newTestAdmin(t)
+
+	moved := decodeAdmin[player](t, adminRequest(t, server, "POST", "/admin/rooms/arena/players/player1/teleport", testAdminToken, `{"x": 50, "y": 60}`))
+	require.Equal(t, 50, moved.X)
+	require.Equal(t, 60, moved.Y)
+
+	resp := adminRequest(t, server, "POST", "/admin/rooms/arena/players/player1/teleport", testAdminToken, `not json`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	p, err := rm.state.getPlayer("player1")
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		name     string
+		health   int
+		body     string
+		expected int
+	}{
+		{name: "partial", health: 10, body: `{"amount": 5}`, expected: 15},
+		{name: "full", health: 10, body: `{}`, expected: p.Health.Max},
+		{name: "capped", health: p.Health.Max - 1, body: `{"amount": 50}`, expected: p.Health.Max},
+		{name: "revive", health: -5, body: `{}`, expected: p.Health.Max},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p.Health.Current = tc.health
+			healed := decodeAdmin[player](t, adminRequest(t, server, "POST", "/admin/rooms/arena/players/player1/heal", testAdminToken, tc.body))
+			require.Equal(t, tc.expected, healed.Health)
+		})
+	}
+}
+
+func TestAdminBroadcast(t *testing.T) {
+	server, rm := newTestAdmin(t)
+
+	resp := adminRequest(t, server, "POST", "/admin/broadcast", testAdminToken, `{"message": "restarting soon"}`)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	for _, name := range []string{"player1", "player2"} {
+		messages := rm.state.chatFor(name)
+		require.Len(t, messages, 1)
+		require.Equal(t, "server", messages[0].Channel)
+		require.Equal(t, "restarting soon", messages[0].Text)
+	}
+
+	resp = adminRequest(t, server, "POST", "/admin/broadcast", testAdminToken, `{"message": " "}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAdminPause(t *testing.T) {
+	server, rm := newTestAdmin(t)
+
+	resp := adminRequest(t, server, "POST", "/admin/rooms/arena/pause", testAdminToken, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	tick := rm.state.tick
+	rm.handle(gameEvent{Type: "refresh"}, "player1")
+	rm.handle(gameEvent{Type: "walk", Data: eventData{player: player{X: 100, Y: 110, Facing: "down"}}}, "player1")
+	p, err := rm.state.getPlayer("player1")
+	require.NoError(t, err)
+	require.Equal(t, tick, rm.state.tick)
+	require.Equal(t, 100, p.Position.Y)
+
+	decoded := snapshot{}
+	require.NoError(t, json.Unmarshal(rm.handle(gameEvent{Type: "refresh"}, "player1"), &decoded))
+	require.True(t, decoded.Paused)
+
+	resp = adminRequest(t, server, "POST", "/admin/rooms/arena/resume", testAdminToken, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	rm.handle(gameEvent{Type: "refresh"}, "player1")
+	require.Equal(t, tick+1, rm.state.tick)
+}
+
+func TestAdminActionsAreRecorded(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	rooms.recordDir = t.TempDir()
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	game := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer game.Close()
+	server := httptest.NewServer(adminAPI{rooms: rooms, token: testAdminToken}.handler())
+	defer server.Close()
+
+	c := dialTestRoom(t, game, "arena")
+	sendTestEvent(t, c, gameEvent{Type: "join", Data: eventData{player: player{X: 100, Y: 100, Name: "player1", Facing: "down"}}})
+
+	// clients can't send the admin api's events
+	decoded := sendTestEvent(t, c, gameEvent{Type: "pause"})
+	require.False(t, decoded.Paused)
+
+	resp := adminRequest(t, server, "POST", "/admin/rooms/arena/players/player1/teleport", testAdminToken, `{"x": 40, "y": 50}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = adminRequest(t, server, "POST", "/admin/rooms/arena/pause", testAdminToken, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = adminRequest(t, server, "POST", "/admin/broadcast", testAdminToken, `{"message": "hello"}`)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = adminRequest(t, server, "POST", "/admin/rooms/arena/players/player1/kick", testAdminToken, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// the kicked player's connection is closed
+	_, _, err := c.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+
+	// which was the room's last, so the room closes and its replay is finished
+	require.Eventually(t, func() bool { return len(rooms.list()) == 0 }, time.Second, 10*time.Millisecond)
+	paths, err := filepath.Glob(filepath.Join(rooms.recordDir, "*.replay.gz"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	_, events, err := loadReplay(paths[0])
+	require.NoError(t, err)
+	types := []string{}
+	for _, e := range events {
+		types = append(types, e.Event.Type)
+	}
+	require.Equal(t, []string{"join", "adminTeleport", "pause", "announce", "adminKick"}, types)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+//go:embed behaviors.json
+var defaultBehaviorsJSON []byte
+
+// behavior trees npcs can use, replaced at startup if a behaviors file is given
+var behaviors = mustParseBehaviors(defaultBehaviorsJSON)
+
+// behaviorNode is one node of a behavior tree
+//
+//   - selector runs its children in order until one succeeds
+//   - sequence runs its children in order until one fails
+//   - condition succeeds if the named condition holds, given value
+//   - action succeeds if the named action could be carried out
+type behaviorNode struct {
+	Type     string         `json:"type"`
+	Name     string         `json:"name"`
+	Value    int            `json:"value"`
+	Children []behaviorNode `json:"children"`
+}
+
+type behaviorCondition func(gs *gameState, e *entity, value int) bool
+type behaviorAction func(gs *gameState, e *entity) bool
+
+var behaviorConditions = map[string]behaviorCondition{
+	"healthBelow":  healthBelowCondition,
+	"targetWithin": targetWithinCondition,
+	"hasStamina":   hasStaminaCondition,
+}
+
+var behaviorActions = map[string]behaviorAction{
+	"idle":   idleAction,
+	"patrol": patrolAction,
+	"chase":  chaseAction,
+	"attack": attackAction,
+	"flee":   fleeAction,
+}
+
+func loadBehaviors(path string) (map[string]behaviorNode, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read behaviors: %w", err)
+	}
+	return parseBehaviors(data)
+}
+
+func mustParseBehaviors(data []byte) map[string]behaviorNode {
+	parsed, err := parseBehaviors(data)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func parseBehaviors(data []byte) (map[string]behaviorNode, error) {
+	parsed := map[string]behaviorNode{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("parse behaviors: %w", err)
+	}
+
+	names := []string{}
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := parsed[name].validate()
+		if err != nil {
+			return nil, fmt.Errorf("behavior %q: %w", name, err)
+		}
+	}
+
+	return parsed, nil
+}
+
+func (n behaviorNode) validate() error {
+	switch n.Type {
+	case "selector", "sequence":
+		if len(n.Children) == 0 {
+			return fmt.Errorf("%s must have children", n.Type)
+		}
+		for _, child := range n.Children {
+			err := child.validate()
+			if err != nil {
+				return err
+			}
+		}
+	case "condition":
+		if _, ok := behaviorConditions[n.Name]; !ok {
+			return fmt.Errorf("unknown condition %q", n.Name)
+		}
+	case "action":
+		if _, ok := behaviorActions[n.Name]; !ok {
+			return fmt.Errorf("unknown action %q", n.Name)
+		}
+	default:
+		return fmt.Errorf("unknown node type %q", n.Type)
+	}
+	return nil
+}
+
+// run evaluates the node for the entity, returning whether it succeeded
+func (n behaviorNode) run(gs *gameState, e *entity) bool {
+	switch n.Type {
+	case "selector":
+		for _, child := range n.Children {
+			if child.run(gs, e) {
+				return true
+			}
+		}
+		return false
+	case "sequence":
+		for _, child := range n.Children {
+			if !child.run(gs, e) {
+				return false
+			}
+		}
+		return true
+	case "condition":
+		return behaviorConditions[n.Name](gs, e, n.Value)
+	case "action":
+		if behaviorActions[n.Name](gs, e) {
+			e.AI.Action = n.Name
+			return true
+		}
+	}
+	return false
+}
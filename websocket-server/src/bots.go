@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// defaultBotDifficulty is how well bots play when the room doesn't say
+const defaultBotDifficulty = "normal"
+
+// botDodgeRange is how close an attacking enemy has to be before bots think about dodging
+const botDodgeRange = 96
+
+// maxBackfill is the most players a room is filled up to with bots, since every bot is simulated each refresh
+const maxBackfill = 16
+
+// botAbilityRange is how close the target has to be before bots use their class ability
+const botAbilityRange = 80
+
+// botDifficulty is how well a bot plays
+type botDifficulty struct {
+	// ReactionTime is how long the bot waits between decisions, in milliseconds
+	ReactionTime int64
+	// DodgeChance is how likely the bot is to dodge an enemy attacking nearby, 0 to 1
+	DodgeChance float64
+	// AbilityChance is how likely the bot is to use its class ability when it can, 0 to 1
+	AbilityChance float64
+}
+
+var botDifficulties = map[string]botDifficulty{
+	"easy":   {ReactionTime: 700},
+	"normal": {ReactionTime: 350, DodgeChance: 0.3, AbilityChance: 0.2},
+	"hard":   {ReactionTime: 150, DodgeChance: 0.8, AbilityChance: 0.6},
+}
+
+// botComponent marks a player the server plays for, to fill rooms without enough people
+// bots play by sending the same events as humans, so they follow the same rules
+type botComponent struct {
+	difficulty   botDifficulty
+	nextDecision int64
+	target       entityID
+}
+
+func (e *entity) isBot() bool {
+	return e.Bot != nil
+}
+
+// botDifficulty is the difficulty of the room's bots
+func (gs *gameState) botDifficulty() botDifficulty {
+	d, ok := botDifficulties[gs.Settings.BotDifficulty]
+	if !ok {
+		if gs.Settings.BotDifficulty != "" {
+			log.Println("unknown bot difficulty:", gs.Settings.BotDifficulty)
+		}
+		return botDifficulties[defaultBotDifficulty]
+	}
+	return d
+}
+
+// playBots fills the room with bots as needed, then lets them play
+// bots aren't a system, since they go through handleEvent like clients do
+func (gs *gameState) playBots() {
+	gs.backfill()
+	gs.decideBots()
+}
+
+// backfill adds bots while the room has fewer people than its backfill setting, and takes them out as people join
+// empty rooms have no bots, there is nobody for them to play with
+func (gs *gameState) backfill() {
+	humans := []*entity{}
+	bots := []*entity{}
+	for _, e := range gs.entities {
+		if e.Kind != "player" {
+			continue
+		}
+		if e.isBot() {
+			bots = append(bots, e)
+			continue
+		}
+		humans = append(humans, e)
+	}
+
+	wanted := 0
+	if len(humans) > 0 {
+		wanted = max(min(gs.Settings.Backfill, maxBackfill)-len(humans), 0)
+	}
+
+	// the newest bots leave first
+	for i := len(bots) - 1; i >= wanted; i-- {
+		gs.handleEvent(gameEvent{Type: "leave", Data: eventData{player: player{Name: bots[i].Actor.Name}}})
+	}
+	for i := len(bots); i < wanted; i++ {
+		gs.addBot()
+	}
+}
+
+// addBot joins a bot with the first free name
+func (gs *gameState) addBot() {
+	name := ""
+	for i := 1; name == ""; i++ {
+		candidate := fmt.Sprintf("bot%d", i)
+		if _, err := gs.getPlayer(candidate); err != nil {
+			name = candidate
+		}
+	}
+
+	x := gs.Bounds.X + gs.rng.Intn(max(gs.Bounds.Width-gs.tuning.Sprite.Width, 1))
+	y := gs.Bounds.Y + gs.rng.Intn(max(gs.Bounds.Height-gs.tuning.Sprite.Height, 1))
+	classNames := sortedKeys(classes)
+	gs.handleEvent(gameEvent{Type: "join", Data: eventData{player: player{
+		X:      x,
+		Y:      y,
+		Name:   name,
+		Class:  classNames[gs.rng.Intn(len(classNames))],
+		Facing: "down",
+		Skin:   "skin2",
+	}}})
+
+	e, err := gs.getPlayer(name)
+	if err != nil {
+		return
+	}
+	e.Actor.IsBot = true
+	e.Bot = &botComponent{difficulty: gs.botDifficulty()}
+	if gs.isHost(name) {
+		gs.Match.Host = ""
+	}
+}
+
+// decideBots lets every bot decide what to do, once its reaction time is up
+func (gs *gameState) decideBots() {
+	now := gs.now()
+	for _, e := range gs.entities {
+		if !e.isBot() || e.isDead() || now < e.Bot.nextDecision {
+			continue
+		}
+		e.Bot.nextDecision = now + e.Bot.difficulty.ReactionTime
+		gs.botDecide(e)
+	}
+}
+
+// botDecide sends the event a player in the bot's place would
+func (gs *gameState) botDecide(e *entity) {
+	name := e.Actor.Name
+	send := func(eventType string, data eventData) {
+		data.Name = name
+		gs.handleEvent(gameEvent{Type: eventType, Data: data})
+	}
+
+	if gs.inLobby() {
+		if !gs.Match.Ready[name] {
+			send("ready", eventData{Ready: true})
+		}
+		return
+	}
+
+	target, ok := gs.botTarget(e)
+	if !ok {
+		return
+	}
+	d := e.Bot.difficulty
+
+	if gs.enemyAttackingNearby(e) && gs.rng.Float64() < d.DodgeChance {
+		send("dodge", eventData{})
+		return
+	}
+
+	x, y := entityCenter(e)
+	targetX, targetY := entityCenter(target)
+	if withinRadius(x, y, targetX, targetY, botAbilityRange) && gs.now() >= e.Actor.AbilityReadyAt && gs.rng.Float64() < d.AbilityChance {
+		send("ability", eventData{})
+		return
+	}
+
+	// attack if the target would be hit facing them, turning to face them first
+	facing := directionToward(x, y, targetX, targetY)
+	if gs.inReachFacing(e, target, facing) {
+		if e.Actor.Facing != facing {
+			send("walk", eventData{player: player{Facing: facing}})
+			return
+		}
+		send("attack", eventData{})
+		return
+	}
+
+	// otherwise head for them, the same way players click to move
+	send("moveTo", eventData{player: player{X: target.Position.X, Y: target.Position.Y}})
+}
+
+// botTarget returns the nearest living player the bot can hurt
+func (gs *gameState) botTarget(e *entity) (*entity, bool) {
+	x, y := entityCenter(e)
+	var nearest *entity
+	nearestDistance := 0
+	for _, other := range gs.entities {
+		if other.Kind != "player" || other.ID == e.ID || other.isDead() || !gs.canHurt(e.Actor.Team, other) {
+			continue
+		}
+		otherX, otherY := entityCenter(other)
+		distance := (otherX-x)*(otherX-x) + (otherY-y)*(otherY-y)
+		if nearest == nil || distance < nearestDistance {
+			nearest = other
+			nearestDistance = distance
+		}
+	}
+	if nearest == nil {
+		return nil, false
+	}
+	e.Bot.target = nearest.ID
+	return nearest, true
+}
+
+// enemyAttackingNearby reports whether anyone who can hurt the bot is swinging at it from close by
+func (gs *gameState) enemyAttackingNearby(e *entity) bool {
+	x, y := entityCenter(e)
+	for _, other := range gs.entities {
+		if other.Actor == nil || other.ID == e.ID || !other.Actor.IsAttacking {
+			continue
+		}
+		if !gs.canHurt(other.Actor.Team, e) {
+			continue
+		}
+		otherX, otherY := entityCenter(other)
+		if withinRadius(x, y, otherX, otherY, botDodgeRange) {
+			return true
+		}
+	}
+	return false
+}
+
+// inReachFacing reports whether the actor's weapon would hit the target if they faced the direction
+func (gs *gameState) inReachFacing(e, target *entity, facing string) bool {
+	current := e.Actor.Facing
+	e.Actor.Facing = facing
+	defer func() { e.Actor.Facing = current }()
+
+	// ranged weapons reach anything in line
+	w := gs.weapon(e.Actor.Weapon)
+	if w.Projectile != "" {
+		x, y := entityCenter(e)
+		targetX, targetY := entityCenter(target)
+		if facing == "left" || facing == "right" {
+			return abs(targetY-y) < e.Sprite.Height/2
+		}
+		return abs(targetX-x) < e.Sprite.Width/2
+	}
+
+	for _, hit := range gs.attackTargets(e) {
+		if hit.ID == target.ID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// botNames returns the names of the bots in the room
+func botNames(gs *gameState) []string {
+	names := []string{}
+	for _, e := range gs.entities {
+		if e.isBot() {
+			names = append(names, e.Actor.Name)
+		}
+	}
+	return names
+}
+
+func TestBackfill(t *testing.T) {
+	gs, _ := newTestClockGameState()
+	gs.Settings.Backfill = 3
+
+	// nobody to play with, so no bots
+	gs.refresh()
+	require.Empty(t, botNames(gs))
+
+	gs.handleEvent(gameEvent{Type: "join", Data: eventData{player: player{Name: "player1", Facing: "down"}}})
+	gs.refresh()
+	require.Equal(t, []string{"bot1", "bot2"}, botNames(gs))
+	require.True(t, getTestPlayer(t, gs, "bot1").IsBot)
+
+	// bots make way for people
+	gs.handleEvent(gameEvent{Type: "join", Data: eventData{player: player{X: 100, Name: "player2", Facing: "down"}}})
+	gs.refresh()
+	require.Equal(t, []string{"bot1"}, botNames(gs))
+
+	gs.handleEvent(gameEvent{Type: "leave", Data: eventData{player: player{Name: "player1"}}})
+	gs.handleEvent(gameEvent{Type: "leave", Data: eventData{player: player{Name: "player2"}}})
+	gs.refresh()
+	require.Empty(t, botNames(gs))
+}
+
+func TestBotsAreNeverHost(t *testing.T) {
+	gs, _ := newTestClockGameState()
+	require.NoError(t, gs.setMode("deathmatch"))
+	gs.Settings.Backfill = 2
+	gs.addPlayer(player{Name: "player1", Facing: "down"})
+	gs.refresh()
+
+	gs.handleEvent(gameEvent{Type: "leave", Data: eventData{player: player{Name: "player1"}}})
+	require.Empty(t, gs.Match.Host)
+	gs.addPlayer(player{Name: "player2", Facing: "down"})
+	require.Equal(t, "player2", gs.Match.Host)
+}
+
+func TestBotsPlayByTheRules(t *testing.T) {
+	gs, c := newTestClockGameState(player{X: playerSpriteWidth + 5, Name: "player1", Class: "warrior", Facing: "left"})
+	gs.Settings.Backfill = 2
+	gs.Settings.BotDifficulty = "easy"
+	gs.addBot()
+	bot, err := gs.getPlayer("bot1")
+	require.NoError(t, err)
+	bot.Position.X = 0
+	bot.Position.Y = 0
+	bot.applyClass("warrior")
+	reaction := botDifficulties["easy"].ReactionTime
+
+	// the bot turns to face the player, then attacks
+	gs.refresh()
+	require.Equal(t, "right", bot.Actor.Facing)
+	c.advance(reaction)
+	gs.refresh()
+	require.True(t, bot.Actor.IsAttacking)
+	w := getWeapon(bot.Actor.Weapon)
+	require.Equal(t, 100-w.Damage, getTestPlayer(t, gs, "player1").Health)
+
+	// it can't attack faster than anyone else
+	bot.Bot.nextDecision = 0
+	gs.refresh()
+	require.Equal(t, 100-w.Damage, getTestPlayer(t, gs, "player1").Health)
+
+	// and stops once it is out of stamina
+	bot.Actor.Resources["stamina"].Current = w.Cost["stamina"] - 1
+	bot.Actor.Resources["stamina"].Regen = 0
+	c.advance(w.SwingDuration + 1)
+	bot.Bot.nextDecision = 0
+	gs.refresh()
+	require.Equal(t, 100-w.Damage, getTestPlayer(t, gs, "player1").Health)
+}
+
+func TestBotsReadyUp(t *testing.T) {
+	gs, _ := newTestClockGameState()
+	require.NoError(t, gs.setMode("deathmatch"))
+	gs.Settings.Backfill = 2
+	gs.addPlayer(player{Name: "player1", Facing: "down"})
+
+	gs.refresh()
+	gs.refresh()
+	require.True(t, gs.Match.Ready["bot1"])
+	require.False(t, gs.Match.Ready["player1"])
+}
+
+func TestBackfillIsCapped(t *testing.T) {
+	gs, _ := newTestClockGameState(player{Name: "player1", Facing: "down"})
+	gs.Settings.Backfill = 2000
+	gs.refresh()
+	require.Len(t, botNames(gs), maxBackfill-1)
+
+	require.Error(t, roomSettings{Backfill: maxBackfill + 1}.validateBots())
+	require.Error(t, roomSettings{Backfill: -1}.validateBots())
+	require.Error(t, roomSettings{BotDifficulty: "impossible"}.validateBots())
+	require.NoError(t, roomSettings{Backfill: maxBackfill, BotDifficulty: "hard"}.validateBots())
+}
+
+func TestBotDifficulty(t *testing.T) {
+	gs := newGameState()
+	require.Equal(t, botDifficulties[defaultBotDifficulty], gs.botDifficulty())
+	gs.Settings.BotDifficulty = "hard"
+	require.Equal(t, botDifficulties["hard"], gs.botDifficulty())
+	gs.Settings.BotDifficulty = "impossible"
+	require.Equal(t, botDifficulties[defaultBotDifficulty], gs.botDifficulty())
+}
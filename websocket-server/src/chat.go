@@ -0,0 +1,167 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// chatHistory is how many chat messages rooms keep for snapshots
+const chatHistory = 50
+
+// maxChatLength is the longest message players can send, in characters, anything longer is cut off
+const maxChatLength = 200
+
+// players can send up to chatRateLimit messages every chatRateWindow milliseconds
+const chatRateLimit = 5
+const chatRateWindow = 5000
+
+// chatMessage is sent to clients in snapshots, clients use the id to tell which ones are new
+type chatMessage struct {
+	ID      int    `json:"id"`
+	From    string `json:"from"`
+	Channel string `json:"channel"`
+	// Team is who can read a team message
+	Team string `json:"team,omitempty"`
+	// To is who can read a whisper or system message, besides whoever sent it
+	To   string `json:"to,omitempty"`
+	Text string `json:"text"`
+	Time int64  `json:"time"`
+}
+
+func (gs *gameState) playerChat(name, channel, target, text string) {
+	p, err := gs.getPlayer(name)
+	if err != nil {
+		log.Println("cannot find player chatting")
+		return
+	}
+	gs.chat(p, channel, target, text)
+}
+
+// chat sends a message to everyone in the room, to the sender's team on the team channel,
+// or to the target on the whisper channel
+func (gs *gameState) chat(e *entity, channel, target, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if runes := []rune(text); len(runes) > maxChatLength {
+		text = string(runes[:maxChatLength])
+	}
+
+	now := gs.now()
+	message := chatMessage{
+		From:    e.Actor.Name,
+		Channel: "all",
+		Text:    text,
+		Time:    now,
+	}
+	switch channel {
+	case "team":
+		if e.Actor.Team == "" {
+			return
+		}
+		message.Channel = "team"
+		message.Team = e.Actor.Team
+	case "whisper":
+		if _, err := gs.getPlayer(target); err != nil || target == e.Actor.Name {
+			gs.systemMessage(e.Actor.Name, "nobody called "+target+" is here")
+			return
+		}
+		message.Channel = "whisper"
+		message.To = target
+	}
+
+	// only messages that could be sent count towards the rate limit
+	if !e.allowChat(now) {
+		// the warning goes in the room's chat history like any message, so flooding only gets one a window
+		if e.Actor.chatWarned == 0 || now-e.Actor.chatWarned >= chatRateWindow {
+			e.Actor.chatWarned = now
+			gs.systemMessage(e.Actor.Name, "you are sending messages too quickly")
+		}
+		return
+	}
+	gs.addChat(message)
+}
+
+// allowChat reports whether the actor can send another message, counting it if they can
+func (e *entity) allowChat(now int64) bool {
+	recent := []int64{}
+	for _, sent := range e.Actor.chatSent {
+		if now-sent < chatRateWindow {
+			recent = append(recent, sent)
+		}
+	}
+	e.Actor.chatSent = recent
+	if len(recent) >= chatRateLimit {
+		return false
+	}
+	e.Actor.chatSent = append(e.Actor.chatSent, now)
+	return true
+}
+
+// systemMessage tells only the named player something from the server
+func (gs *gameState) systemMessage(to, text string) {
+	gs.addChat(chatMessage{
+		Channel: "system",
+		To:      to,
+		Text:    text,
+		Time:    gs.now(),
+	})
+}
+
+// announce sends a message from the server to everyone in the room
+func (gs *gameState) announce(text string) {
+	gs.addChat(chatMessage{
+		Channel: "server",
+		Text:    text,
+		Time:    gs.now(),
+	})
+}
+
+func (gs *gameState) addChat(message chatMessage) {
+	gs.nextChatID++
+	message.ID = gs.nextChatID
+	gs.chatLog = append(gs.chatLog, message)
+	if len(gs.chatLog) > chatHistory {
+		gs.chatLog = gs.chatLog[len(gs.chatLog)-chatHistory:]
+	}
+}
+
+// playerMute hides or shows the target's messages to the named player
+func (gs *gameState) playerMute(name, target string, muted bool) {
+	p, err := gs.getPlayer(name)
+	if err != nil {
+		log.Println("cannot find player muting")
+		return
+	}
+	if muted {
+		p.Actor.muted[target] = true
+		return
+	}
+	delete(p.Actor.muted, target)
+}
+
+// chatFor returns the recent messages the named player can read
+func (gs *gameState) chatFor(viewer string) []chatMessage {
+	team := ""
+	muted := map[string]bool{}
+	if p, err := gs.getPlayer(viewer); err == nil {
+		team = p.Actor.Team
+		muted = p.Actor.muted
+	}
+
+	messages := []chatMessage{}
+	for _, m := range gs.chatLog {
+		if m.Channel == "team" && m.Team != team {
+			continue
+		}
+		if m.To != "" && (viewer == "" || (m.To != viewer && m.From != viewer)) {
+			continue
+		}
+		if muted[m.From] {
+			continue
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// chatTexts returns the text of every message the viewer can read
+func chatTexts(gs *gameState, viewer string) []string {
+	texts := []string{}
+	for _, m := range gs.chatFor(viewer) {
+		texts = append(texts, m.Text)
+	}
+	return texts
+}
+
+func TestTeamChat(t *testing.T) {
+	gs := newTestTeamGameState(
+		player{Name: "player1", Team: "red", Facing: "down"},
+		player{Name: "player2", Team: "red", Facing: "down"},
+		player{Name: "player3", Team: "blue", Facing: "down"},
+	)
+
+	gs.handleEvent(gameEvent{Type: "chat", Data: eventData{player: player{Name: "player1"}, Message: "hello everyone"}})
+	gs.handleEvent(gameEvent{Type: "chat", Data: eventData{player: player{Name: "player1"}, Channel: "team", Message: " go left "}})
+	gs.handleEvent(gameEvent{Type: "chat", Data: eventData{player: player{Name: "player3"}, Channel: "team", Message: "they're going left"}})
+	gs.handleEvent(gameEvent{Type: "chat", Data: eventData{player: player{Name: "player3"}, Message: "   "}})
+
+	require.Equal(t, []string{"hello everyone", "go left"}, chatTexts(gs, "player2"))
+	require.Equal(t, []string{"hello everyone", "they're going left"}, chatTexts(gs, "player3"))
+	// anyone who hasn't joined only sees messages to everyone
+	require.Equal(t, []string{"hello everyone"}, chatTexts(gs, ""))
+
+	messages := gs.chatFor("player1")
+	require.Equal(t, chatMessage{ID: 2, From: "player1", Channel: "team", Team: "red", Text: "go left", Time: messages[1].Time}, messages[1])
+}
+
+func TestTeamChatWithoutTeams(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	gs.playerChat("player1", "team", "", "anyone?")
+	require.Empty(t, chatTexts(gs, "player1"))
+}
+
+func TestChatHistory(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, err := gs.getPlayer("player1")
+	require.NoError(t, err)
+	for i := 0; i < chatHistory+10; i++ {
+		// get around the rate limit
+		p.Actor.chatSent = nil
+		gs.playerChat("player1", "", "", fmt.Sprint(i))
+	}
+	messages := gs.chatFor("player1")
+	require.Len(t, messages, chatHistory)
+	require.Equal(t, "10", messages[0].Text)
+	require.Equal(t, chatHistory+10, messages[len(messages)-1].ID)
+}
+
+func TestWhisper(t *testing.T) {
+	gs := newTestGameState(
+		player{Name: "player1", Facing: "down"},
+		player{X: 100, Name: "player2", Facing: "down"},
+		player{X: 200, Name: "player3", Facing: "down"},
+	)
+
+	gs.handleEvent(gameEvent{Type: "chat", Data: eventData{player: player{Name: "player1"}, Channel: "whisper", Target: "player2", Message: "psst"}})
+	gs.handleEvent(gameEvent{Type: "chat", Data: eventData{player: player{Name: "player1"}, Channel: "whisper", Target: "player9", Message: "hello?"}})
+
+	require.Equal(t, []string{"psst", "nobody called player9 is here"}, chatTexts(gs, "player1"))
+	require.Equal(t, []string{"psst"}, chatTexts(gs, "player2"))
+	require.Empty(t, chatTexts(gs, "player3"))
+	require.Empty(t, chatTexts(gs, ""))
+}
+
+func TestChatLimits(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+
+	// long messages are cut off
+	gs.playerChat("player1", "", "", strings.Repeat("é", maxChatLength+10))
+	require.Equal(t, []string{strings.Repeat("é", maxChatLength)}, chatTexts(gs, "player1"))
+
+	// sending too many messages too quickly gets a warning instead
+	for i := 1; i < chatRateLimit+1; i++ {
+		gs.playerChat("player1", "", "", fmt.Sprint(i))
+	}
+	texts := chatTexts(gs, "player1")
+	require.Len(t, texts, chatRateLimit+1)
+	require.Equal(t, "you are sending messages too quickly", texts[len(texts)-1])
+}
+
+func TestRejectedChatIsNotRateLimited(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+
+	// messages that can't be sent don't use up the allowance
+	for i := 0; i < chatRateLimit; i++ {
+		gs.playerChat("player1", "team", "", "anyone?")
+		gs.playerChat("player1", "whisper", "player9", "hello?")
+	}
+	gs.playerChat("player1", "", "", "hello")
+	texts := chatTexts(gs, "player1")
+	require.Equal(t, "hello", texts[len(texts)-1])
+	require.NotContains(t, texts, "you are sending messages too quickly")
+}
+
+func TestChatWarningsAreLimited(t *testing.T) {
+	gs, c := newTestClockGameState(testPlayer1FacingRight, player{X: 100, Name: "player2", Facing: "down"})
+	gs.playerChat("player2", "", "", "hello")
+
+	// flooding only gets one warning, so it doesn't push everyone else's messages out of the history
+	for i := 0; i < chatHistory*2; i++ {
+		gs.playerChat("player1", "", "", fmt.Sprint(i))
+	}
+	require.Len(t, gs.chatLog, chatRateLimit+2)
+	require.Equal(t, "hello", chatTexts(gs, "player2")[0])
+
+	// until the next window
+	c.advance(chatRateWindow)
+	for i := 0; i < chatRateLimit+2; i++ {
+		gs.playerChat("player1", "", "", fmt.Sprint(i))
+	}
+	require.Len(t, gs.chatLog, chatRateLimit*2+3)
+}
+
+func TestMute(t *testing.T) {
+	gs := newTestGameState(
+		player{Name: "player1", Facing: "down"},
+		player{X: 100, Name: "player2", Facing: "down"},
+	)
+	gs.playerChat("player1", "", "", "spam")
+
+	gs.handleEvent(gameEvent{Type: "mute", Data: eventData{player: player{Name: "player2"}, Target: "player1"}})
+	require.Empty(t, chatTexts(gs, "player2"))
+	require.Equal(t, []string{"spam"}, chatTexts(gs, "player1"))
+
+	gs.handleEvent(gameEvent{Type: "unmute", Data: eventData{player: player{Name: "player2"}, Target: "player1"}})
+	require.Equal(t, []string{"spam"}, chatTexts(gs, "player2"))
+}
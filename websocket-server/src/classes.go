@@ -0,0 +1,279 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+)
+
+const defaultClass = "warrior"
+
+//go:embed classes.json
+var defaultClassesJSON []byte
+
+// classes players can pick when they join, replaced at startup if a classes file is given
+var classes = mustParseClasses(defaultClassesJSON)
+
+type class struct {
+	Name   string `json:"-"`
+	Health int    `json:"health"`
+	// Resources are the pools the class has, like stamina and mana
+	Resources map[string]resourcePoolKind `json:"resources"`
+	// Speed multiplies how far the class walks each step
+	Speed float64 `json:"speed"`
+	// Weapon is the weapon the class starts with, Weapons are all the ones it can use
+	Weapon  string   `json:"weapon"`
+	Weapons []string `json:"weapons"`
+	Ability ability  `json:"ability"`
+}
+
+// ability is a class's special move, carried out by the named ability action
+type ability struct {
+	Action string `json:"action"`
+	// Cooldown is how long until the ability can be used again in milliseconds
+	Cooldown int64        `json:"cooldown"`
+	Cost     resourceCost `json:"cost"`
+	Damage   int          `json:"damage"`
+	Reach    int          `json:"reach"`
+	Effects  []string     `json:"effects"`
+}
+
+// abilityAction carries out an ability, reporting whether it was used
+type abilityAction func(gs *gameState, e *entity, a ability) bool
+
+var abilityActions = map[string]abilityAction{
+	"bash": bashAbility,
+	"dash": dashAbility,
+	"nova": novaAbility,
+}
+
+func loadClasses(path string) (map[string]class, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read classes: %w", err)
+	}
+	return parseClasses(data)
+}
+
+func mustParseClasses(data []byte) map[string]class {
+	parsed, err := parseClasses(data)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func parseClasses(data []byte) (map[string]class, error) {
+	parsed := map[string]class{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("parse classes: %w", err)
+	}
+
+	if _, ok := parsed[defaultClass]; !ok {
+		return nil, fmt.Errorf("classes must include %q", defaultClass)
+	}
+
+	names := []string{}
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := parsed[name]
+		c.Name = name
+		err := c.validate()
+		if err != nil {
+			return nil, fmt.Errorf("class %q: %w", name, err)
+		}
+		parsed[name] = c
+	}
+
+	return parsed, nil
+}
+
+func (c class) validate() error {
+	if c.Health <= 0 {
+		return fmt.Errorf("health must be positive")
+	}
+	for _, name := range sortedKeys(c.Resources) {
+		if err := c.Resources[name].validate(); err != nil {
+			return fmt.Errorf("resource %q: %w", name, err)
+		}
+	}
+	if c.Speed <= 0 || c.Speed > 2 {
+		return fmt.Errorf("speed must be between 0 and 2")
+	}
+	if _, ok := weapons[c.Weapon]; !ok {
+		return fmt.Errorf("unknown weapon %q", c.Weapon)
+	}
+	for _, name := range c.Weapons {
+		if _, ok := weapons[name]; !ok {
+			return fmt.Errorf("unknown weapon %q", name)
+		}
+	}
+	if len(c.Weapons) > 0 && !slices.Contains(c.Weapons, c.Weapon) {
+		return fmt.Errorf("weapons must include %q", c.Weapon)
+	}
+
+	a := c.Ability
+	if _, ok := abilityActions[a.Action]; !ok {
+		return fmt.Errorf("unknown ability action %q", a.Action)
+	}
+	if a.Cooldown < 0 || a.Damage < 0 {
+		return fmt.Errorf("ability cooldown and damage cannot be negative")
+	}
+	if err := a.Cost.validate(); err != nil {
+		return fmt.Errorf("ability: %w", err)
+	}
+	for _, name := range a.Cost.names() {
+		if _, ok := c.Resources[name]; !ok {
+			return fmt.Errorf("ability costs %q, which the class doesn't have", name)
+		}
+	}
+	if a.Reach <= 0 {
+		return fmt.Errorf("ability reach must be positive")
+	}
+	return validateEffects(a.Effects)
+}
+
+// selectClass returns the class name to give a joining player
+func selectClass(name string) string {
+	if name == "" {
+		return defaultClass
+	}
+	if _, ok := classes[name]; !ok {
+		log.Println("unknown class:", name)
+		return defaultClass
+	}
+	return name
+}
+
+// allows reports whether the class can use the weapon
+func (c class) allows(weapon string) bool {
+	if len(c.Weapons) == 0 {
+		return weapon == c.Weapon
+	}
+	return slices.Contains(c.Weapons, weapon)
+}
+
+// canWield reports whether the actor's class lets them use the weapon, actors without a class can use anything
+func (e *entity) canWield(weapon string) bool {
+	c, ok := classes[e.Actor.Class]
+	return !ok || c.allows(weapon)
+}
+
+// baseWeapon is the weapon the actor goes back to when they have nothing else
+func (e *entity) baseWeapon() string {
+	if c, ok := classes[e.Actor.Class]; ok {
+		return c.Weapon
+	}
+	return defaultWeapon
+}
+
+// applyClass gives the player the class's stats, whatever the client asked for
+func (e *entity) applyClass(name string) {
+	c := classes[selectClass(name)]
+	e.Health.Current = c.Health
+	e.Health.Max = c.Health
+	e.Actor.Class = c.Name
+	e.Actor.Resources = map[string]*resourcePool{}
+	for name, kind := range c.Resources {
+		e.Actor.Resources[name] = newResourcePool(kind)
+	}
+	e.Actor.speed = c.Speed
+	if e.Actor.Weapon == defaultWeapon || !c.allows(e.Actor.Weapon) {
+		e.Actor.Weapon = c.Weapon
+	}
+}
+
+func (gs *gameState) playerAbility(name string) {
+	p, err := gs.getPlayer(name)
+	if err != nil {
+		log.Println("cannot find player using ability")
+		return
+	}
+	gs.useAbility(p)
+}
+
+// useAbility carries out the actor's class ability, if it is off cooldown and they can afford it
+func (gs *gameState) useAbility(e *entity) {
+	c, ok := classes[e.Actor.Class]
+	if !ok || e.isBusy() || e.Actor.IsAttacking {
+		return
+	}
+
+	now := gs.now()
+	a := c.Ability
+	if now < e.Actor.AbilityReadyAt || !gs.canAfford(e, a.Cost) {
+		return
+	}
+
+	if !abilityActions[a.Action](gs, e, a) {
+		return
+	}
+	gs.spend(e, a.Cost)
+	e.Actor.AbilityReadyAt = now + a.Cooldown
+}
+
+// hitWithAbility damages everything in the shape and applies the ability's effects to them
+func (gs *gameState) hitWithAbility(e *entity, a ability, shape hitShape) {
+	for _, target := range gs.entitiesHitByShape(e, shape, 0) {
+		if a.Damage > 0 {
+			gs.hit(e, target, a.Damage)
+		}
+		for _, effect := range a.Effects {
+			gs.applyEffect(target, effect)
+		}
+	}
+}
+
+// bashAbility hits everything just in front of the actor
+func bashAbility(gs *gameState, e *entity, a ability) bool {
+	e.Actor.IsAttacking = true
+	e.Actor.lastAttack = gs.now()
+	gs.hitWithAbility(e, a, weapon{Shape: "rect", Reach: a.Reach}.hitShape(e))
+	return true
+}
+
+// dashAbility rushes forward up to reach, stopping at anything in the way, and can't be hit meanwhile
+func dashAbility(gs *gameState, e *entity, a ability) bool {
+	e.Actor.IsDodging = true
+	e.Actor.lastDodge = gs.now()
+	e.Actor.path = nil
+
+	dx, dy := 0, 0
+	switch e.Actor.Facing {
+	case "up":
+		dy = -1
+	case "down":
+		dy = 1
+	case "left":
+		dx = -1
+	case "right":
+		dx = 1
+	}
+	for i := 0; i < a.Reach; i++ {
+		x, y := e.Position.X, e.Position.Y
+		gs.moveEntity(e, x+dx, y+dy)
+		if e.Position.X == x && e.Position.Y == y {
+			break
+		}
+	}
+	return true
+}
+
+// novaAbility hits everything all around the actor
+func novaAbility(gs *gameState, e *entity, a ability) bool {
+	x, y := entityCenter(e)
+	gs.hitWithAbility(e, a, circleHitShape{
+		centerX: x,
+		centerY: y,
+		radius:  e.Sprite.Width/2 + a.Reach,
+	})
+	return true
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClasses(t *testing.T) {
+	valid := `"warrior": {"health": 100, "resources": {"stamina": {"max": 100}}, "speed": 1, "weapon": "sword", "ability": {"action": "bash", "reach": 10}}`
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"valid", `{` + valid + `}`, ""},
+		{"no default", `{"rogue": {"health": 100, "resources": {"stamina": {"max": 100}}, "speed": 1, "weapon": "sword", "ability": {"action": "bash", "reach": 10}}}`, `classes must include "warrior"`},
+		{"no health", `{"warrior": {"resources": {"stamina": {"max": 100}}, "speed": 1, "weapon": "sword", "ability": {"action": "bash", "reach": 10}}}`, `class "warrior": health must be positive`},
+		{"too fast", `{"warrior": {"health": 100, "resources": {"stamina": {"max": 100}}, "speed": 3, "weapon": "sword", "ability": {"action": "bash", "reach": 10}}}`, `class "warrior": speed must be between 0 and 2`},
+		{"unknown weapon", `{"warrior": {"health": 100, "resources": {"stamina": {"max": 100}}, "speed": 1, "weapon": "axe", "ability": {"action": "bash", "reach": 10}}}`, `class "warrior": unknown weapon "axe"`},
+		{"weapon not allowed", `{"warrior": {"health": 100, "resources": {"stamina": {"max": 100}}, "speed": 1, "weapon": "sword", "weapons": ["bow"], "ability": {"action": "bash", "reach": 10}}}`, `class "warrior": weapons must include "sword"`},
+		{"unknown ability", `{"warrior": {"health": 100, "resources": {"stamina": {"max": 100}}, "speed": 1, "weapon": "sword", "ability": {"action": "fly", "reach": 10}}}`, `class "warrior": unknown ability action "fly"`},
+		{"negative cost", `{"warrior": {"health": 100, "resources": {"stamina": {"max": 100}}, "speed": 1, "weapon": "sword", "ability": {"action": "bash", "reach": 10, "cost": {"stamina": -1}}}}`, `class "warrior": ability: stamina cost cannot be negative`},
+		{"missing resource", `{"warrior": {"health": 100, "resources": {"stamina": {"max": 100}}, "speed": 1, "weapon": "sword", "ability": {"action": "bash", "reach": 10, "cost": {"mana": 1}}}}`, `class "warrior": ability costs "mana", which the class doesn't have`},
+		{"empty resource", `{"warrior": {"health": 100, "resources": {"stamina": {}}, "speed": 1, "weapon": "sword", "ability": {"action": "bash", "reach": 10}}}`, `class "warrior": resource "stamina": max must be positive`},
+		{"unknown effect", `{"warrior": {"health": 100, "resources": {"stamina": {"max": 100}}, "speed": 1, "weapon": "sword", "ability": {"action": "bash", "reach": 10, "effects": ["sleep"]}}}`, `class "warrior": unknown effect "sleep"`},
+		{"not json", `[`, "parse classes: unexpected end of JSON input"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseClasses([]byte(test.json))
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestJoinEnforcesClassStats(t *testing.T) {
+	tests := []struct {
+		class    string
+		weapon   string
+		expected string
+		// expectedWeapon is the weapon the player should end up with
+		expectedWeapon string
+	}{
+		{"", "", "warrior", "sword"},
+		{"rogue", "", "rogue", "dagger"},
+		{"rogue", "bow", "rogue", "bow"},
+		{"mage", "hammer", "mage", "staff"},
+		{"pirate", "", "warrior", "sword"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.class, func(t *testing.T) {
+			// whatever the client says about their stats is ignored
+			gs := newTestGameState(player{Name: "player1", Class: test.class, Weapon: test.weapon, Health: 999, Stamina: 999, Facing: "down"})
+			c := classes[test.expected]
+
+			p := getTestPlayer(t, gs, "player1")
+			require.Equal(t, test.expected, p.Class)
+			require.Equal(t, c.Health, p.Health)
+			require.Equal(t, c.Resources["stamina"].Max, p.Stamina)
+			require.Equal(t, test.expectedWeapon, p.Weapon)
+		})
+	}
+}
+
+func TestClassSpeed(t *testing.T) {
+	gs := newTestGameState(
+		player{Name: "player1", Class: "rogue", Facing: "down"},
+		player{X: 100, Name: "player2", Class: "warrior", Facing: "down"},
+	)
+	gs.playerWalk("player1", "down")
+	gs.playerWalk("player2", "down")
+	require.Equal(t, 3, getTestPlayer(t, gs, "player1").Y)
+	require.Equal(t, 2, getTestPlayer(t, gs, "player2").Y)
+}
+
+func TestClassWeaponRestrictions(t *testing.T) {
+	gs := newTestGameState(player{Name: "player1", Class: "mage", Facing: "down"})
+	p, _ := gs.getPlayer("player1")
+	p.Inventory.add("hammer", 1)
+	p.Inventory.add("dagger", 1)
+
+	gs.playerUseItem("player1", "hammer")
+	require.Equal(t, "staff", p.Actor.Weapon)
+
+	gs.playerUseItem("player1", "dagger")
+	require.Equal(t, "dagger", p.Actor.Weapon)
+
+	// dropping it goes back to the class weapon
+	gs.playerDropItem("player1", "dagger", 1)
+	require.Equal(t, "staff", p.Actor.Weapon)
+}
+
+func TestBashAbility(t *testing.T) {
+	gs := newTestGameState(
+		testPlayer1FacingRight,
+		player{X: playerSpriteWidth + 5, Y: 0, Name: "player2", Facing: "left", Skin: "skin2"},
+	)
+	bash := classes["warrior"].Ability
+
+	gs.handleEvent(gameEvent{Type: "ability", Data: eventData{player: player{Name: "player1"}}})
+	p1, _ := gs.getPlayer("player1")
+	p2, _ := gs.getPlayer("player2")
+	require.Equal(t, 100-bash.Damage, p2.Health.Current)
+	require.Equal(t, []string{"stun"}, effectNames(p2))
+	require.Equal(t, 100-bash.Cost["stamina"], p1.resource("stamina"))
+
+	// it can't be used again until the cooldown is over
+	p1.Actor.IsAttacking = false
+	gs.playerAbility("player1")
+	require.Equal(t, 100-bash.Damage, p2.Health.Current)
+
+	p1.Actor.AbilityReadyAt = 0
+	gs.playerAbility("player1")
+	require.Equal(t, 100-2*bash.Damage, p2.Health.Current)
+}
+
+func TestDashAbility(t *testing.T) {
+	gs := newTestGameState(player{Name: "player1", Class: "rogue", Facing: "right"})
+	p, _ := gs.getPlayer("player1")
+	dash := classes["rogue"].Ability
+
+	gs.playerAbility("player1")
+	require.Equal(t, dash.Reach, p.Position.X)
+	require.True(t, p.Actor.IsDodging)
+
+	// walls stop the dash short
+	gs.Walls = []boundingBox{{X: p.hitboxBox().X + p.Hitbox.Width + 10, Y: -50, Width: 10, Height: 100}}
+	p.Actor.AbilityReadyAt = 0
+	gs.playerAbility("player1")
+	require.Equal(t, dash.Reach+9, p.Position.X)
+}
+
+func TestNovaAbility(t *testing.T) {
+	nova := classes["mage"].Ability
+	gs := newTestGameState(
+		player{X: 100, Y: 100, Name: "player1", Class: "mage", Facing: "right"},
+		player{X: 100, Y: 100 - playerSpriteHeight - nova.Reach/2, Name: "player2", Facing: "down"},
+		player{X: 100, Y: 100 + playerSpriteHeight + nova.Reach*2, Name: "player3", Facing: "up"},
+	)
+
+	// not enough mana
+	p1, _ := gs.getPlayer("player1")
+	p1.Actor.Resources["mana"].Current = nova.Cost["mana"] - 1
+	gs.playerAbility("player1")
+	p2, _ := gs.getPlayer("player2")
+	require.Empty(t, effectNames(p2))
+
+	p1.Actor.Resources["mana"].Current = nova.Cost["mana"]
+	gs.playerAbility("player1")
+	require.Equal(t, []string{"slow"}, effectNames(p2))
+	require.Equal(t, classes["warrior"].Health-nova.Damage, p2.Health.Current)
+	p3, _ := gs.getPlayer("player3")
+	require.Empty(t, effectNames(p3))
+	require.Empty(t, effectNames(p1))
+}
@@ -0,0 +1,186 @@
+// Package client speaks the game server's websocket protocol, for bots and tools written in Go.
+//
+// The protocol is request and response: every event sent gets the room's
+// snapshot back, so clients send refresh events to keep up with the game
+// the same way the frontend does.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Player is a player as the server sends them in snapshots, and as clients describe themselves when joining
+type Player struct {
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Name        string `json:"name"`
+	Class       string `json:"class,omitempty"`
+	Team        string `json:"team,omitempty"`
+	Health      int    `json:"health"`
+	Stamina     int    `json:"stamina"`
+	Facing      string `json:"facing"`
+	IsAttacking bool   `json:"isAttacking"`
+	IsWalking   bool   `json:"isWalking"`
+	IsDodging   bool   `json:"isDodging"`
+	Skin        string `json:"skin"`
+	Weapon      string `json:"weapon,omitempty"`
+	Kills       int    `json:"kills"`
+	Deaths      int    `json:"deaths"`
+}
+
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Snapshot is the part of the game state clients usually need
+type Snapshot struct {
+	Players []Player `json:"players"`
+	Walls   []Rect   `json:"walls"`
+	Bounds  Rect     `json:"bounds"`
+	Tick    int64    `json:"tick"`
+}
+
+// Player returns the named player, if they are in the snapshot
+func (s Snapshot) Player(name string) (Player, bool) {
+	for _, p := range s.Players {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Player{}, false
+}
+
+// DecodeSnapshot decodes a snapshot as sent by the server
+func DecodeSnapshot(data []byte) (Snapshot, error) {
+	s := Snapshot{}
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return s, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return s, nil
+}
+
+type event struct {
+	Type string    `json:"type"`
+	Data eventData `json:"data"`
+}
+
+type eventData struct {
+	Player
+	Target string `json:"target,omitempty"`
+}
+
+// Client is one connection to the server, playing as one player
+type Client struct {
+	conn *websocket.Conn
+	name string
+	// mu makes sure each event gets its own snapshot back
+	mu            sync.Mutex
+	bytesSent     int64
+	bytesReceived int64
+	latency       time.Duration
+}
+
+// Dial connects to the server's websocket, for example ws://localhost:8181/state, in the named room
+func Dial(server, room string) (*Client, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("parse server url: %w", err)
+	}
+	if room != "" {
+		query := u.Query()
+		query.Set("room", room)
+		u.RawQuery = query.Encode()
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", u, err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Join joins the game as the player, every other event is sent as them
+func (c *Client) Join(p Player) (Snapshot, error) {
+	c.name = p.Name
+	return c.send(event{Type: "join", Data: eventData{Player: p}})
+}
+
+// Refresh advances the game and returns its state
+func (c *Client) Refresh() (Snapshot, error) {
+	return c.send(event{Type: "refresh"})
+}
+
+// Walk takes a step in the direction, up, down, left or right
+func (c *Client) Walk(direction string) (Snapshot, error) {
+	return c.send(event{Type: "walk", Data: eventData{Player: Player{Name: c.name, Facing: direction}}})
+}
+
+func (c *Client) Attack() (Snapshot, error) {
+	return c.send(event{Type: "attack", Data: eventData{Player: Player{Name: c.name}}})
+}
+
+func (c *Client) Dodge() (Snapshot, error) {
+	return c.send(event{Type: "dodge", Data: eventData{Player: Player{Name: c.name}}})
+}
+
+// Leave takes the player out of the game, the connection stays open
+func (c *Client) Leave() (Snapshot, error) {
+	return c.send(event{Type: "leave", Data: eventData{Player: Player{Name: c.name}}})
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Name is the player the client joined as
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Latency is how long the last event took to get its snapshot back
+func (c *Client) Latency() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.latency
+}
+
+// Bytes returns how much the client has sent and received, in message payload bytes
+func (c *Client) Bytes() (sent, received int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytesSent, c.bytesReceived
+}
+
+// send writes the event and waits for the snapshot the server replies with
+func (c *Client) send(e event) (Snapshot, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("encode %s event: %w", e.Type, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	start := time.Now()
+	err = c.conn.WriteMessage(websocket.TextMessage, data)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("send %s event: %w", e.Type, err)
+	}
+	_, reply, err := c.conn.ReadMessage()
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("read snapshot: %w", err)
+	}
+	c.latency = time.Since(start)
+	c.bytesSent += int64(len(data))
+	c.bytesReceived += int64(len(reply))
+	return DecodeSnapshot(reply)
+}
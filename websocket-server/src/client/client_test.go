@@ -0,0 +1,89 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer answers every event with a snapshot of the players who have joined,
+// moving them a step when they walk, like the real server
+func newTestServer(t *testing.T) string {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "arena", r.URL.Query().Get("room"))
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+
+		s := Snapshot{Players: []Player{}}
+		for {
+			e := event{}
+			if c.ReadJSON(&e) != nil {
+				return
+			}
+			s.Tick++
+			switch e.Type {
+			case "join":
+				s.Players = append(s.Players, e.Data.Player)
+			case "walk":
+				s.Players[0].Y += 2
+			case "leave":
+				s.Players = []Player{}
+			}
+			require.NoError(t, c.WriteJSON(s))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/state"
+}
+
+func TestClient(t *testing.T) {
+	c, err := Dial(newTestServer(t), "arena")
+	require.NoError(t, err)
+	defer c.Close()
+
+	s, err := c.Join(Player{Name: "bot1", Facing: "down", Health: 100})
+	require.NoError(t, err)
+	require.Equal(t, "bot1", c.Name())
+	p, ok := s.Player("bot1")
+	require.True(t, ok)
+	require.Equal(t, 100, p.Health)
+
+	s, err = c.Walk("down")
+	require.NoError(t, err)
+	p, _ = s.Player("bot1")
+	require.Equal(t, 2, p.Y)
+	require.Positive(t, c.Latency())
+
+	s, err = c.Leave()
+	require.NoError(t, err)
+	require.Empty(t, s.Players)
+	require.Equal(t, int64(3), s.Tick)
+
+	sent, received := c.Bytes()
+	require.Positive(t, sent)
+	require.Positive(t, received)
+}
+
+func TestDecodeSnapshot(t *testing.T) {
+	data, err := json.Marshal(map[string]any{
+		"players":  []map[string]any{{"name": "player1", "x": 5, "health": 90}},
+		"entities": []any{},
+		"tick":     7,
+	})
+	require.NoError(t, err)
+
+	s, err := DecodeSnapshot(data)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), s.Tick)
+	require.Equal(t, []Player{{Name: "player1", X: 5, Health: 90}}, s.Players)
+
+	_, err = DecodeSnapshot([]byte("{"))
+	require.ErrorContains(t, err, "decode snapshot:")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// clock tells the simulation what time it is, in unix milliseconds
+// rooms use the system clock, tests and replays use a manual one so time only moves when they say
+type clock interface {
+	now() int64
+}
+
+type systemClock struct{}
+
+func (systemClock) now() int64 {
+	return time.Now().UnixMilli()
+}
+
+// manualClock stays at the same time until it is advanced
+type manualClock struct {
+	time int64
+}
+
+func (c *manualClock) now() int64 {
+	return c.time
+}
+
+// advance moves the clock on by the given milliseconds
+func (c *manualClock) advance(milliseconds int64) {
+	c.time += milliseconds
+}
+
+// now is the room's current time, every timing rule in the simulation goes by it
+// the room's time stands still while it is paused, so nothing runs out while nobody can play
+func (gs *gameState) now() int64 {
+	if gs.handling {
+		return gs.handledAt
+	}
+	if gs.paused {
+		return gs.pausedAt
+	}
+	return gs.clock.now() - gs.pausedFor
+}
+
+// pause stops the room's time, and its events besides the ones in pausedEvents
+func (gs *gameState) pause() {
+	if gs.paused {
+		return
+	}
+	gs.pausedAt = gs.now()
+	gs.paused = true
+}
+
+// resume carries on from when the room was paused
+func (gs *gameState) resume() {
+	if !gs.paused {
+		return
+	}
+	gs.paused = false
+	gs.pausedFor = gs.clock.now() - gs.pausedAt
+}
+
+// handleEventAt handles the event with the room's time held at now, however long it takes
+// that way the time it is recorded with is the time every system sees, and replays play it out the same
+func (gs *gameState) handleEventAt(event gameEvent, now int64) {
+	gs.handling = true
+	gs.handledAt = now
+	defer func() { gs.handling = false }()
+	gs.handleEvent(event)
+}
+
+// seed makes the room's randomness repeat for the same seed, so replays play out the same
+func (gs *gameState) seed(seed int64) {
+	gs.rngSeed = seed
+	gs.rng = rand.New(rand.NewSource(seed))
+}
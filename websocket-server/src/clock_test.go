@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClockGameState returns a room where time only moves when the test advances the clock
+func newTestClockGameState(players ...player) (*gameState, *manualClock) {
+	c := &manualClock{time: 1000}
+	gs := newGameState()
+	gs.clock = c
+	gs.seed(1)
+	for _, p := range players {
+		gs.addPlayer(p)
+	}
+	return gs, c
+}
+
+func TestActionsTimeOut(t *testing.T) {
+	gs, c := newTestClockGameState(testPlayer1FacingRight)
+	swing := getWeapon(getTestPlayer(t, gs, "player1").Weapon).SwingDuration
+
+	gs.playerAttack("player1")
+	gs.playerDodge("player1")
+	c.advance(swing)
+	gs.refresh()
+	require.True(t, getTestPlayer(t, gs, "player1").IsAttacking)
+
+	// dodges are over before swings
+	c.advance(1)
+	gs.refresh()
+	p := getTestPlayer(t, gs, "player1")
+	require.False(t, p.IsAttacking)
+	require.False(t, p.IsDodging)
+}
+
+func TestAbilityCooldown(t *testing.T) {
+	gs, c := newTestClockGameState(player{Name: "player1", Class: "rogue", Facing: "right"})
+	p, err := gs.getPlayer("player1")
+	require.NoError(t, err)
+	cooldown := classes["rogue"].Ability.Cooldown
+
+	gs.playerAbility("player1")
+	require.Equal(t, c.now()+cooldown, p.Actor.AbilityReadyAt)
+	x := p.Position.X
+
+	c.advance(cooldown - 1)
+	gs.refresh()
+	gs.playerAbility("player1")
+	require.Equal(t, x, p.Position.X)
+
+	c.advance(1)
+	gs.refresh()
+	gs.playerAbility("player1")
+	require.Greater(t, p.Position.X, x)
+}
+
+func TestStaminaRegenDelay(t *testing.T) {
+	gs, c := newTestClockGameState(player{Name: "player1", Class: "rogue", Facing: "right"})
+	p, err := gs.getPlayer("player1")
+	require.NoError(t, err)
+	stamina := p.Actor.Resources["stamina"]
+
+	gs.playerDodge("player1")
+	spent := stamina.Current
+	c.advance(stamina.RegenDelay - 1)
+	gs.refresh()
+	require.Equal(t, spent, stamina.Current)
+
+	c.advance(1)
+	gs.refresh()
+	require.Equal(t, spent+stamina.Regen, stamina.Current)
+}
+
+func TestTicks(t *testing.T) {
+	gs, _ := newTestClockGameState()
+	for i := 0; i < 3; i++ {
+		gs.handleEvent(gameEvent{Type: "refresh"})
+	}
+	require.Equal(t, int64(3), gs.tick)
+}
+
+func TestSeededRandomness(t *testing.T) {
+	a, _ := newTestClockGameState()
+	b, _ := newTestClockGameState()
+	for i := 0; i < 10; i++ {
+		require.Equal(t, a.rng.Int63(), b.rng.Int63())
+	}
+}
+
+func TestPauseFreezesTime(t *testing.T) {
+	gs, c := newTestClockGameState(player{Name: "player1", Facing: "down"}, player{X: 100, Name: "player2", Facing: "down"})
+	require.NoError(t, gs.setMode("deathmatch"))
+	gs.setPhase(phaseInProgress, 60000)
+	left := gs.Match.PhaseEndsAt - gs.now()
+
+	// nothing runs out while the room is paused, however long it is paused for
+	gs.pause()
+	c.advance(70000)
+	require.Equal(t, left, gs.Match.PhaseEndsAt-gs.now())
+	gs.resume()
+	gs.refresh()
+	require.Equal(t, phaseInProgress, gs.Match.Phase)
+	require.Equal(t, left, gs.Match.PhaseEndsAt-gs.now())
+
+	// and time carries on once it is resumed
+	c.advance(1000)
+	require.Equal(t, left-1000, gs.Match.PhaseEndsAt-gs.now())
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	"toast-websocket-server/src/client"
+)
+
+var directions = []string{"up", "down", "left", "right"}
+
+// behavior decides what a bot does on a refresh, returning false to do nothing
+type behavior func(b *bot, s client.Snapshot) (func() (client.Snapshot, error), string, bool)
+
+var behaviors = map[string]behavior{
+	"random":     randomBehavior,
+	"aggressive": aggressiveBehavior,
+}
+
+// behaviorNames are shared out between bots in a mixed test
+var behaviorNames = []string{"random", "aggressive"}
+
+type bot struct {
+	name     string
+	behavior behavior
+	rng      *rand.Rand
+	client   *client.Client
+	stats    *stats
+}
+
+// run plays until stop is closed, refreshing like the frontend and acting on what it sees
+func (b *bot) run(server, room string, stop chan struct{}) error {
+	c, err := client.Dial(server, room)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	b.client = c
+
+	s, err := b.timed("join", func() (client.Snapshot, error) {
+		return c.Join(client.Player{Name: b.name, Facing: "down", Health: 100, Stamina: 100, Skin: "skin1"})
+	})
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			_, err = b.timed("leave", c.Leave)
+			return err
+		case <-ticker.C:
+		}
+
+		s, err = b.timed("refresh", c.Refresh)
+		if err != nil {
+			return err
+		}
+		b.stats.sawTick(s.Tick)
+		if action, name, ok := b.behavior(b, s); ok {
+			s, err = b.timed(name, action)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// timed sends an event, recording how long it took and how many bytes it cost
+func (b *bot) timed(name string, send func() (client.Snapshot, error)) (client.Snapshot, error) {
+	sentBefore, receivedBefore := b.client.Bytes()
+	s, err := send()
+	if err != nil {
+		return s, err
+	}
+	sent, received := b.client.Bytes()
+	b.stats.record(name, b.client.Latency(), sent-sentBefore, received-receivedBefore)
+	return s, nil
+}
+
+// randomBehavior wanders about, now and then attacking or dodging
+func randomBehavior(b *bot, s client.Snapshot) (func() (client.Snapshot, error), string, bool) {
+	switch roll := b.rng.Intn(10); {
+	case roll < 5:
+		direction := directions[b.rng.Intn(len(directions))]
+		return func() (client.Snapshot, error) { return b.client.Walk(direction) }, "walk", true
+	case roll == 5:
+		return b.client.Attack, "attack", true
+	case roll == 6:
+		return b.client.Dodge, "dodge", true
+	}
+	return nil, "", false
+}
+
+// aggressiveBehavior walks at the nearest other player and attacks once it is next to them
+func aggressiveBehavior(b *bot, s client.Snapshot) (func() (client.Snapshot, error), string, bool) {
+	me, ok := s.Player(b.name)
+	if !ok {
+		return nil, "", false
+	}
+
+	var target client.Player
+	nearest := -1
+	for _, p := range s.Players {
+		if p.Name == b.name || p.Health <= 0 {
+			continue
+		}
+		distance := abs(p.X-me.X) + abs(p.Y-me.Y)
+		if nearest == -1 || distance < nearest {
+			target = p
+			nearest = distance
+		}
+	}
+	if nearest == -1 {
+		return randomBehavior(b, s)
+	}
+
+	dx := target.X - me.X
+	dy := target.Y - me.Y
+	if abs(dx) < 56 && abs(dy) < 56 {
+		return b.client.Attack, "attack", true
+	}
+	direction := "down"
+	switch {
+	case abs(dx) > abs(dy) && dx > 0:
+		direction = "right"
+	case abs(dx) > abs(dy):
+		direction = "left"
+	case dy < 0:
+		direction = "up"
+	}
+	return func() (client.Snapshot, error) { return b.client.Walk(direction) }, "walk", true
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
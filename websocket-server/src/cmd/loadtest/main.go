@@ -0,0 +1,89 @@
+// Command loadtest connects bots to a running server and reports how it holds up.
+//
+//	go run ./src/cmd/loadtest -bots 50 -behavior mixed -duration 30s
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// refreshInterval is how often bots refresh, the same as the frontend
+const refreshInterval = 50 * time.Millisecond
+
+func main() {
+	var server = flag.String("server", "ws://localhost:8181/state", "websocket url of the server to test")
+	var room = flag.String("room", "loadtest", "room the bots join")
+	var bots = flag.Int("bots", 10, "number of bots to connect")
+	var behavior = flag.String("behavior", "mixed", "how bots play: random, aggressive or mixed")
+	var duration = flag.Duration("duration", 30*time.Second, "how long to run the test for")
+	var seed = flag.Int64("seed", 0, "random seed, defaults to one based on the time")
+	var metrics = flag.String("metrics", "http://localhost:8181/metrics", "metrics url of the server to read tick durations from, empty to not read them")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	if _, ok := behaviors[*behavior]; !ok && *behavior != "mixed" {
+		log.Fatalf("unknown behavior %q", *behavior)
+	}
+
+	// the server's tick durations are read before and after, so the report only covers ticks run during the test
+	var ticksBefore *tickHistogram
+	if *metrics != "" {
+		h, err := scrapeTicks(*metrics)
+		if err != nil {
+			log.Println(err)
+		} else {
+			ticksBefore = &h
+		}
+	}
+
+	s := newStats()
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < *bots; i++ {
+		name := *behavior
+		if name == "mixed" {
+			name = behaviorNames[i%len(behaviorNames)]
+		}
+		b := &bot{
+			name:     fmt.Sprintf("loadtest%d", i+1),
+			behavior: behaviors[name],
+			rng:      rand.New(rand.NewSource(*seed + int64(i))),
+			stats:    s,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := b.run(*server, *room, stop)
+			if err != nil {
+				log.Println(b.name+":", err)
+				s.error()
+			}
+		}()
+	}
+
+	start := time.Now()
+	time.Sleep(*duration)
+	close(stop)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var ticks *tickHistogram
+	if ticksBefore != nil {
+		h, err := scrapeTicks(*metrics)
+		if err != nil {
+			log.Println(err)
+		} else {
+			h = h.since(*ticksBefore)
+			ticks = &h
+		}
+	}
+	s.report(elapsed, ticks)
+}
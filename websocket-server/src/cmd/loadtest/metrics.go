@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+// tickMetric is the server's tick duration histogram, which covers every room on the server
+const tickMetric = "toast_tick_duration_seconds"
+
+// tickHistogram is how long the server's ticks have taken, as scraped from its metrics endpoint
+type tickHistogram struct {
+	count uint64
+	sum   float64
+	// buckets are the cumulative tick counts at or under each upper bound, in seconds
+	buckets []tickBucket
+}
+
+type tickBucket struct {
+	upperBound float64
+	count      uint64
+}
+
+// scrapeTicks reads the tick duration histogram from the server's metrics endpoint
+func scrapeTicks(url string) (tickHistogram, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return tickHistogram{}, fmt.Errorf("scrape metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return tickHistogram{}, fmt.Errorf("scrape metrics: %s", resp.Status)
+	}
+
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return tickHistogram{}, fmt.Errorf("parse metrics: %w", err)
+	}
+	family, ok := families[tickMetric]
+	if !ok || len(family.GetMetric()) == 0 {
+		return tickHistogram{}, fmt.Errorf("parse metrics: no %s", tickMetric)
+	}
+	histogram := family.GetMetric()[0].GetHistogram()
+	h := tickHistogram{count: histogram.GetSampleCount(), sum: histogram.GetSampleSum()}
+	for _, b := range histogram.GetBucket() {
+		h.buckets = append(h.buckets, tickBucket{upperBound: b.GetUpperBound(), count: b.GetCumulativeCount()})
+	}
+	return h, nil
+}
+
+// since returns the ticks run between the earlier scrape and this one
+func (h tickHistogram) since(before tickHistogram) tickHistogram {
+	diff := tickHistogram{count: h.count - before.count, sum: h.sum - before.sum}
+	for i, b := range h.buckets {
+		if i < len(before.buckets) {
+			b.count -= before.buckets[i].count
+		}
+		diff.buckets = append(diff.buckets, b)
+	}
+	return diff
+}
+
+func (h tickHistogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return fromSeconds(h.sum / float64(h.count))
+}
+
+// percentile returns the upper bound of the bucket the p percentile tick falls in,
+// the histogram doesn't keep anything finer than that
+func (h tickHistogram) percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(float64(h.count) * p / 100))
+	for _, b := range h.buckets {
+		if b.count >= rank {
+			return fromSeconds(b.upperBound)
+		}
+	}
+	// ticks slower than the last bucket only have infinity as their bound
+	return fromSeconds(math.Inf(1))
+}
+
+func fromSeconds(s float64) time.Duration {
+	if math.IsInf(s, 1) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tickMetrics is a metrics page with a tick duration histogram, count ticks of which are at or under each bound
+func tickMetrics(counts [3]int, sum float64) string {
+	return fmt.Sprintf(`# HELP toast_tick_duration_seconds How long refreshes take to run every system.
+# TYPE toast_tick_duration_seconds histogram
+toast_tick_duration_seconds_bucket{le="0.001"} %d
+toast_tick_duration_seconds_bucket{le="0.002"} %d
+toast_tick_duration_seconds_bucket{le="+Inf"} %d
+toast_tick_duration_seconds_sum %g
+toast_tick_duration_seconds_count %d
+`, counts[0], counts[1], counts[2], sum, counts[2])
+}
+
+func TestScrapeTicks(t *testing.T) {
+	page := tickMetrics([3]int{10, 10, 10}, 0.005)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	before, err := scrapeTicks(server.URL)
+	require.NoError(t, err)
+	page = tickMetrics([3]int{60, 100, 110}, 0.155)
+	after, err := scrapeTicks(server.URL)
+	require.NoError(t, err)
+
+	// only the ticks between the scrapes count
+	ticks := after.since(before)
+	require.Equal(t, uint64(100), ticks.count)
+	require.Equal(t, 1500*time.Microsecond, ticks.mean().Round(time.Microsecond))
+	require.Equal(t, time.Millisecond, ticks.percentile(50))
+	require.Equal(t, 2*time.Millisecond, ticks.percentile(90))
+	require.Equal(t, time.Duration(math.MaxInt64), ticks.percentile(99))
+
+	page = "# TYPE other counter\nother 1\n"
+	_, err = scrapeTicks(server.URL)
+	require.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// stats collects every bot's measurements
+type stats struct {
+	mu            sync.Mutex
+	latencies     map[string][]time.Duration
+	bytesSent     int64
+	bytesReceived int64
+	errors        int
+	// firstTick and lastTick are the room's tick counter at the start and end of the test
+	firstTick int64
+	lastTick  int64
+}
+
+func newStats() *stats {
+	return &stats{latencies: map[string][]time.Duration{}}
+}
+
+func (s *stats) record(event string, latency time.Duration, sent, received int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies[event] = append(s.latencies[event], latency)
+	s.bytesSent += sent
+	s.bytesReceived += received
+}
+
+func (s *stats) sawTick(tick int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.firstTick == 0 || tick < s.firstTick {
+		s.firstTick = tick
+	}
+	s.lastTick = max(s.lastTick, tick)
+}
+
+func (s *stats) error() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors++
+}
+
+// percentile returns the latency p percent of the sorted latencies are at or under
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// report prints latency by event and bandwidth over the test, and how long the server's ticks took if it has them
+// every refresh runs a server tick, so refresh latency is how long ticks take plus the round trip
+func (s *stats) report(elapsed time.Duration, durations *tickHistogram) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Printf("%-10s %8s %10s %10s %10s %10s\n", "event", "count", "p50", "p95", "p99", "max")
+	events := []string{}
+	for event := range s.latencies {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	for _, event := range events {
+		latencies := s.latencies[event]
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Printf("%-10s %8d %10s %10s %10s %10s\n", event, len(latencies),
+			percentile(latencies, 50), percentile(latencies, 95), percentile(latencies, 99), latencies[len(latencies)-1])
+	}
+
+	seconds := elapsed.Seconds()
+	ticks := s.lastTick - s.firstTick
+	if ticks > 0 {
+		fmt.Printf("\ntick rate: %.1f per second, %s apart on average\n", float64(ticks)/seconds, elapsed/time.Duration(ticks))
+	}
+	// the server's tick durations are for every room on it, not just the one being tested
+	if durations != nil && durations.count > 0 {
+		fmt.Printf("tick duration: %s mean, p50 <= %s, p95 <= %s, p99 <= %s over %d ticks\n",
+			durations.mean(), durations.percentile(50), durations.percentile(95), durations.percentile(99), durations.count)
+	}
+	fmt.Printf("bandwidth: %.1f KB/s sent, %.1f KB/s received\n", float64(s.bytesSent)/1024/seconds, float64(s.bytesReceived)/1024/seconds)
+	fmt.Printf("errors: %d\n", s.errors)
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentile(t *testing.T) {
+	latencies := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	require.Equal(t, 50*time.Millisecond, percentile(latencies, 50))
+	require.Equal(t, 95*time.Millisecond, percentile(latencies, 95))
+	require.Equal(t, 100*time.Millisecond, percentile(latencies, 100))
+	require.Equal(t, time.Millisecond, percentile(latencies, 0))
+	require.Zero(t, percentile(nil, 50))
+}
@@ -0,0 +1,133 @@
+package main
+
+const flagSize = 24
+
+// flagComponent is a team's flag, which the other teams try to carry back to their own
+type flagComponent struct {
+	Team string `json:"team"`
+	// Carrier is the player holding the flag, 0 if it is on the ground
+	Carrier entityID `json:"carrier"`
+	// home is where the flag is returned to
+	home positionComponent
+}
+
+// captureTheFlagMode scores a point for the team each time it brings an enemy flag home
+type captureTheFlagMode struct{}
+
+func (m *captureTheFlagMode) teams() bool            { return true }
+func (m *captureTheFlagMode) defaultScoreLimit() int { return 3 }
+
+// start puts a flag in the middle of each team's spawn area, replacing any left from the last match
+func (m *captureTheFlagMode) start(gs *gameState) {
+	startTeamScores(gs)
+	for _, e := range gs.entities {
+		if e.Flag != nil {
+			gs.removeEntity(e.ID)
+		}
+	}
+	for _, t := range gs.Teams {
+		gs.spawnFlag(t)
+	}
+}
+
+func (m *captureTheFlagMode) update(gs *gameState)                         {}
+func (m *captureTheFlagMode) killed(gs *gameState, killer, victim *entity) {}
+
+func (m *captureTheFlagMode) winner(gs *gameState) (string, bool) {
+	return gs.scoreLimitWinner()
+}
+
+func (gs *gameState) spawnFlag(t team) *entity {
+	home := positionComponent{
+		X: t.Spawn.X + (t.Spawn.Width-flagSize)/2,
+		Y: t.Spawn.Y + (t.Spawn.Height-flagSize)/2,
+	}
+	return gs.addEntity(&entity{
+		Kind:     "flag",
+		Position: &positionComponent{X: home.X, Y: home.Y},
+		Hitbox:   &hitboxComponent{Width: flagSize, Height: flagSize},
+		Sprite:   &spriteComponent{Width: flagSize, Height: flagSize, Skin: "flag-" + t.Name},
+		Flag:     &flagComponent{Team: t.Name, home: home},
+	})
+}
+
+// isHome reports whether the flag is sitting where it belongs
+func (e *entity) isHome() bool {
+	return e.Flag.Carrier == 0 && e.Position.X == e.Flag.home.X && e.Position.Y == e.Flag.home.Y
+}
+
+func (e *entity) returnHome() {
+	e.Flag.Carrier = 0
+	e.Position.X = e.Flag.home.X
+	e.Position.Y = e.Flag.home.Y
+}
+
+// carrying returns the flag the player is holding, if any
+func (gs *gameState) carrying(p *entity) (*entity, bool) {
+	for _, e := range gs.entities {
+		if e.Flag != nil && e.Flag.Carrier == p.ID {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// flagSystem lets players pick up, return and capture flags while a match is in progress
+func flagSystem(gs *gameState) {
+	if gs.Match == nil || gs.Match.Phase != phaseInProgress {
+		return
+	}
+
+	for _, flag := range gs.entities {
+		if flag.Flag == nil {
+			continue
+		}
+
+		// carried flags move with their carrier, and drop where they are if the carrier dies or leaves
+		if flag.Flag.Carrier != 0 {
+			carrier, err := gs.getEntity(flag.Flag.Carrier)
+			if err != nil || carrier.isDead() {
+				flag.Flag.Carrier = 0
+				continue
+			}
+			flag.Position.X = carrier.Position.X
+			flag.Position.Y = carrier.Position.Y
+			gs.captureFlag(flag, carrier)
+			continue
+		}
+
+		for _, e := range gs.entities {
+			if e.Kind != "player" || e.isDead() || e.Actor.Team == "" {
+				continue
+			}
+			if !boxesOverlap(e.hitboxBox(), flag.hitboxBox()) {
+				continue
+			}
+
+			// teammates return their dropped flag, enemies pick it up
+			if e.Actor.Team == flag.Flag.Team {
+				if !flag.isHome() {
+					flag.returnHome()
+				}
+				continue
+			}
+			if _, ok := gs.carrying(e); !ok {
+				flag.Flag.Carrier = e.ID
+				break
+			}
+		}
+	}
+}
+
+// captureFlag scores for the carrier's team if they have brought the flag to their own flag at home
+func (gs *gameState) captureFlag(flag, carrier *entity) {
+	for _, own := range gs.entities {
+		if own.Flag == nil || own.Flag.Team != carrier.Actor.Team || !own.isHome() {
+			continue
+		}
+		if boxesOverlap(carrier.hitboxBox(), own.hitboxBox()) {
+			gs.Match.Scores[carrier.Actor.Team]++
+			flag.returnHome()
+		}
+	}
+}
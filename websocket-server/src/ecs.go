@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+
+	"toast-websocket-server/src/pathfinding"
+)
+
+type entityID int
+
+// entity is anything in the game world. What an entity is and does
+// is decided by the components it has, nil components are absent.
+type entity struct {
+	ID         entityID             `json:"id"`
+	Kind       string               `json:"kind"`
+	Position   *positionComponent   `json:"position,omitempty"`
+	Velocity   *velocityComponent   `json:"velocity,omitempty"`
+	Health     *healthComponent     `json:"health,omitempty"`
+	Hitbox     *hitboxComponent     `json:"hitbox,omitempty"`
+	Sprite     *spriteComponent     `json:"sprite,omitempty"`
+	Actor      *actorComponent      `json:"actor,omitempty"`
+	Projectile *projectileComponent `json:"projectile,omitempty"`
+	AI         *aiComponent         `json:"ai,omitempty"`
+	Item       *itemComponent       `json:"item,omitempty"`
+	Flag       *flagComponent       `json:"flag,omitempty"`
+	Bot        *botComponent        `json:"-"`
+	Inventory  *inventoryComponent  `json:"-"`
+	Lifetime   *lifetimeComponent   `json:"-"`
+}
+
+type positionComponent struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// velocityComponent is the distance moved every refresh
+type velocityComponent struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type healthComponent struct {
+	Current     int `json:"current"`
+	Max         int `json:"max"`
+	lastDamaged int64
+	// lastHitBy is who gets the kill if the entity dies
+	lastHitBy entityID
+}
+
+// hitboxComponent is the collision box, relative to the entity's position
+// solid hitboxes block the movement of other solid hitboxes
+type hitboxComponent struct {
+	OffsetX int  `json:"offsetX"`
+	OffsetY int  `json:"offsetY"`
+	Width   int  `json:"width"`
+	Height  int  `json:"height"`
+	Solid   bool `json:"solid"`
+}
+
+// spriteComponent is what gets drawn at the entity's position, and what attacks hit
+type spriteComponent struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Skin   string `json:"skin"`
+}
+
+// actorComponent is anything that walks, attacks and dodges
+type actorComponent struct {
+	Name        string                   `json:"name"`
+	Class       string                   `json:"class"`
+	Team        string                   `json:"team"`
+	Facing      string                   `json:"facing"`
+	Resources   map[string]*resourcePool `json:"resources"`
+	Weapon      string                   `json:"weapon"`
+	IsAttacking bool                     `json:"isAttacking"`
+	IsWalking   bool                     `json:"isWalking"`
+	IsDodging   bool                     `json:"isDodging"`
+	IsUsing     bool                     `json:"isUsing"`
+	Effects     []statusEffect           `json:"effects"`
+	// AbilityReadyAt is when the class ability comes off cooldown, in unix milliseconds
+	AbilityReadyAt int64 `json:"abilityReadyAt"`
+	Kills          int   `json:"kills"`
+	Deaths         int   `json:"deaths"`
+	// IsBot is set for players the server plays for
+	IsBot      bool `json:"isBot"`
+	lastAttack int64
+	lastWalk   int64
+	lastDodge  int64
+	lastUse    int64
+	// useDuration is how long the item being used takes
+	useDuration int64
+	// speed multiplies how far the actor walks each step
+	speed float64
+	// path is where the actor is walking to, in hitbox positions
+	path []pathfinding.Point
+	// spawn is where the actor joined, and comes back to when there is no team spawn
+	spawn positionComponent
+	// respawnAt is when a dead player comes back, in unix milliseconds
+	respawnAt int64
+	// chatSent is when the actor sent their recent chat messages, for rate limiting
+	chatSent []int64
+	// chatWarned is when the actor was last told they are sending messages too quickly
+	chatWarned int64
+	// muted is whose chat messages the actor doesn't want to see
+	muted map[string]bool
+}
+
+type projectileComponent struct {
+	Kind    string   `json:"kind"`
+	Owner   entityID `json:"owner"`
+	team    string
+	damage  int
+	effects []string
+}
+
+// lifetimeComponent removes the entity once it expires
+type lifetimeComponent struct {
+	expiresAt int64
+}
+
+// system updates the entities it cares about on every refresh
+type system func(gs *gameState)
+
+// systems run in order on every refresh
+var systems = []system{
+	actionTimeoutSystem,
+	regenSystem,
+	resourceSystem,
+	hazardSystem,
+	effectSystem,
+	respawnSystem,
+	aiSystem,
+	pathSystem,
+	spawnerSystem,
+	pickupSystem,
+	projectileSystem,
+	flagSystem,
+	matchSystem,
+	deathSystem,
+	lifetimeSystem,
+}
+
+func (gs *gameState) addEntity(e *entity) *entity {
+	gs.nextEntityID++
+	e.ID = gs.nextEntityID
+	gs.entities = append(gs.entities, e)
+	return e
+}
+
+func (gs *gameState) removeEntity(id entityID) {
+	remaining := []*entity{}
+	for _, e := range gs.entities {
+		if e.ID != id {
+			remaining = append(remaining, e)
+		}
+	}
+	gs.entities = remaining
+}
+
+func (gs *gameState) getEntity(id entityID) (*entity, error) {
+	for _, e := range gs.entities {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, errors.New("entity not found")
+}
+
+// spriteBox is the area the entity's sprite covers in the world
+func (e *entity) spriteBox() boundingBox {
+	return boundingBox{
+		X:      e.Position.X,
+		Y:      e.Position.Y,
+		Width:  e.Sprite.Width,
+		Height: e.Sprite.Height,
+	}
+}
+
+// hitboxAt is the area the entity's hitbox would cover if it was at x, y
+func (e *entity) hitboxAt(x, y int) boundingBox {
+	return boundingBox{
+		X:      x + e.Hitbox.OffsetX,
+		Y:      y + e.Hitbox.OffsetY,
+		Width:  e.Hitbox.Width,
+		Height: e.Hitbox.Height,
+	}
+}
+
+func (e *entity) hitboxBox() boundingBox {
+	return e.hitboxAt(e.Position.X, e.Position.Y)
+}
+
+// isBusy reports whether the actor is dead, in the middle of using an item or locked by an effect, and can't do anything else
+func (e *entity) isBusy() bool {
+	return e.Actor != nil && (e.isDead() || e.Actor.IsUsing || e.isLocked())
+}
+
+// isDead reports whether the entity has run out of health
+func (e *entity) isDead() bool {
+	return e.Health != nil && e.Health.Current <= 0
+}
+
+// isDodging reports whether attacks should pass through the entity
+func (e *entity) isDodging() bool {
+	return e.Actor != nil && e.Actor.IsDodging
+}
+
+// damage reduces the target's health, if it has any, and records the death if it runs out
+func (gs *gameState) damage(target *entity, amount int) {
+	if target.Health == nil || target.isDead() {
+		return
+	}
+	target.Health.Current -= amount
+	target.Health.lastDamaged = gs.now()
+	if target.isDead() {
+		gs.killed(target)
+	}
+}
+
+// hit damages the target on behalf of the attacker, who gets the kill if it dies
+func (gs *gameState) hit(attacker *entity, target *entity, amount int) {
+	if target.Health == nil {
+		return
+	}
+	if attacker != nil {
+		target.Health.lastHitBy = attacker.ID
+	}
+	gs.damage(target, amount)
+}
+
+// actionTimeoutSystem ends actions once they have been going on long enough
+func actionTimeoutSystem(gs *gameState) {
+	now := gs.now()
+	for _, e := range gs.entities {
+		a := e.Actor
+		if a == nil {
+			continue
+		}
+		if a.IsAttacking && now-a.lastAttack > gs.weapon(a.Weapon).SwingDuration {
+			a.IsAttacking = false
+		}
+		if a.IsWalking && now-a.lastWalk > gs.tuning.WalkTimeout {
+			a.IsWalking = false
+		}
+		if a.IsDodging && now-a.lastDodge > gs.tuning.DodgeTimeout {
+			a.IsDodging = false
+		}
+		if a.IsUsing && now-a.lastUse > a.useDuration {
+			a.IsUsing = false
+		}
+	}
+}
+
+// lifetimeSystem removes expired entities
+func lifetimeSystem(gs *gameState) {
+	now := gs.now()
+	for _, e := range gs.entities {
+		if e.Lifetime != nil && now > e.Lifetime.expiresAt {
+			gs.removeEntity(e.ID)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddAndRemoveEntities(t *testing.T) {
+	gs := newGameState()
+	first := gs.addEntity(&entity{Kind: "projectile"})
+	second := gs.addEntity(&entity{Kind: "projectile"})
+	require.NotEqual(t, first.ID, second.ID)
+
+	gs.removeEntity(first.ID)
+	_, err := gs.getEntity(first.ID)
+	require.Error(t, err)
+
+	found, err := gs.getEntity(second.ID)
+	require.NoError(t, err)
+	require.Same(t, second, found)
+}
+
+func TestSnapshotIncludesEveryEntity(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	owner, _ := gs.getPlayer("player1")
+	gs.spawnProjectile(owner, "arrow", 10)
+
+	decoded := struct {
+		Players  []player `json:"players"`
+		Entities []struct {
+			ID         int    `json:"id"`
+			Kind       string `json:"kind"`
+			Projectile *struct {
+				Kind string `json:"kind"`
+			} `json:"projectile"`
+		} `json:"entities"`
+	}{}
+	require.NoError(t, json.Unmarshal(gs.toJSON(""), &decoded))
+
+	require.Equal(t, []player{owner.toPlayer()}, decoded.Players)
+	require.Len(t, decoded.Entities, 2)
+	require.Equal(t, "player", decoded.Entities[0].Kind)
+	require.Nil(t, decoded.Entities[0].Projectile)
+	require.Equal(t, "projectile", decoded.Entities[1].Kind)
+	require.Equal(t, "arrow", decoded.Entities[1].Projectile.Kind)
+}
+
+func TestSolidEntitiesBlockMovement(t *testing.T) {
+	gs := newTestGameState(
+		testPlayer1FacingRight,
+		player{X: playerHitboxWidth + 2, Y: 0, Name: "player2", Health: 100, Facing: "left", Skin: "skin2"},
+	)
+
+	// player2's hitbox is in the way
+	gs.playerWalk("player1", "right")
+	require.Equal(t, 0, getTestPlayer(t, gs, "player1").X)
+
+	// hitboxes that aren't solid don't block anyone
+	p, _ := gs.getPlayer("player1")
+	hitbox := p.hitboxBox()
+	gs.addEntity(&entity{
+		Kind:     "projectile",
+		Position: &positionComponent{X: hitbox.X, Y: hitbox.Y + 2},
+		Hitbox:   &hitboxComponent{Width: 8, Height: 8},
+	})
+	gs.playerWalk("player1", "down")
+	require.Equal(t, 2, getTestPlayer(t, gs, "player1").Y)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+//go:embed effects.json
+var defaultEffectsJSON []byte
+
+// status effects that can be applied to actors, replaced at startup if an effects file is given
+var effectKinds = mustParseEffectKinds(defaultEffectsJSON)
+
+// effectKind is a timed status effect
+//
+// Applying an effect the actor already has follows its stacking rule:
+//   - refresh restarts the duration
+//   - stack adds a stack, up to max stacks, and restarts the duration
+//   - extend adds the duration on to what is left
+//   - ignore leaves the existing effect as it is
+type effectKind struct {
+	Name string `json:"-"`
+	// Duration is how long the effect lasts in milliseconds
+	Duration  int64  `json:"duration"`
+	Stacking  string `json:"stacking"`
+	MaxStacks int    `json:"maxStacks"`
+	// Interval is how often damage, heal and resources are applied in milliseconds, every refresh if 0
+	// they are multiplied by the number of stacks
+	Interval  int64          `json:"interval"`
+	Damage    int            `json:"damage"`
+	Heal      int            `json:"heal"`
+	Resources map[string]int `json:"resources"`
+	// Speed multiplies how far the actor walks, 0 leaves it alone
+	Speed float64 `json:"speed"`
+	// Lock stops the actor doing anything until the effect ends
+	Lock bool `json:"lock"`
+}
+
+// statusEffect is an effect on an actor, sent to clients so they can show it
+type statusEffect struct {
+	Name   string `json:"name"`
+	Stacks int    `json:"stacks"`
+	// ExpiresAt is when the effect ends, in unix milliseconds
+	ExpiresAt int64 `json:"expiresAt"`
+	nextTick  int64
+}
+
+// hazard is an area of the map that applies an effect to every actor standing in it
+type hazard struct {
+	boundingBox
+	Effect string `json:"effect"`
+}
+
+func loadEffectKinds(path string) (map[string]effectKind, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read effects: %w", err)
+	}
+	return parseEffectKinds(data)
+}
+
+func mustParseEffectKinds(data []byte) map[string]effectKind {
+	parsed, err := parseEffectKinds(data)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func parseEffectKinds(data []byte) (map[string]effectKind, error) {
+	parsed := map[string]effectKind{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("parse effects: %w", err)
+	}
+
+	names := []string{}
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		k := parsed[name]
+		k.Name = name
+		err := k.validate()
+		if err != nil {
+			return nil, fmt.Errorf("effect %q: %w", name, err)
+		}
+		parsed[name] = k
+	}
+
+	return parsed, nil
+}
+
+func (k effectKind) validate() error {
+	if k.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if k.Interval < 0 {
+		return fmt.Errorf("interval cannot be negative")
+	}
+	if k.Damage < 0 || k.Heal < 0 {
+		return fmt.Errorf("damage and heal cannot be negative")
+	}
+	if k.Speed < 0 || k.Speed > 1 {
+		return fmt.Errorf("speed must be between 0 and 1")
+	}
+
+	switch k.Stacking {
+	case "refresh", "extend", "ignore":
+	case "stack":
+		if k.MaxStacks <= 0 {
+			return fmt.Errorf("max stacks must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown stacking %q", k.Stacking)
+	}
+
+	return nil
+}
+
+// validateEffects checks every name is a known effect
+func validateEffects(names []string) error {
+	for _, name := range names {
+		if _, ok := effectKinds[name]; !ok {
+			return fmt.Errorf("unknown effect %q", name)
+		}
+	}
+	return nil
+}
+
+// applyEffect puts the named effect on the actor, following its stacking rule
+func (gs *gameState) applyEffect(e *entity, name string) {
+	kind, ok := effectKinds[name]
+	if !ok || e.Actor == nil || e.Health == nil || e.Health.Current <= 0 {
+		return
+	}
+
+	now := gs.now()
+	for i := range e.Actor.Effects {
+		effect := &e.Actor.Effects[i]
+		if effect.Name != name {
+			continue
+		}
+		switch kind.Stacking {
+		case "refresh":
+			effect.ExpiresAt = now + kind.Duration
+		case "stack":
+			effect.Stacks = min(effect.Stacks+1, kind.MaxStacks)
+			effect.ExpiresAt = now + kind.Duration
+		case "extend":
+			effect.ExpiresAt += kind.Duration
+		}
+		return
+	}
+
+	e.Actor.Effects = append(e.Actor.Effects, statusEffect{
+		Name:      name,
+		Stacks:    1,
+		ExpiresAt: now + kind.Duration,
+		nextTick:  now + kind.Interval,
+	})
+	if kind.Lock {
+		// being locked interrupts whatever the actor was doing
+		e.Actor.IsWalking = false
+		e.Actor.path = nil
+	}
+}
+
+// isLocked reports whether an effect is stopping the actor from doing anything
+func (e *entity) isLocked() bool {
+	if e.Actor == nil {
+		return false
+	}
+	for _, effect := range e.Actor.Effects {
+		if effectKinds[effect.Name].Lock {
+			return true
+		}
+	}
+	return false
+}
+
+// speedMultiplier is how much the actor's effects slow them down by
+func (e *entity) speedMultiplier() float64 {
+	multiplier := 1.0
+	if e.Actor == nil {
+		return multiplier
+	}
+	for _, effect := range e.Actor.Effects {
+		kind := effectKinds[effect.Name]
+		if kind.Speed > 0 {
+			multiplier *= math.Pow(kind.Speed, float64(effect.Stacks))
+		}
+	}
+	return multiplier
+}
+
+// hazardSystem applies each hazard's effect to every actor standing in it
+func hazardSystem(gs *gameState) {
+	for _, h := range gs.Hazards {
+		for _, e := range gs.entities {
+			if e.Actor == nil || e.Hitbox == nil {
+				continue
+			}
+			if boxesOverlap(h.boundingBox, e.hitboxBox()) {
+				gs.applyEffect(e, h.Effect)
+			}
+		}
+	}
+}
+
+// effectSystem applies every effect that is due and removes the ones that have run out
+func effectSystem(gs *gameState) {
+	now := gs.now()
+	for _, e := range gs.entities {
+		if e.Actor == nil || len(e.Actor.Effects) == 0 {
+			continue
+		}
+
+		remaining := []statusEffect{}
+		for _, effect := range e.Actor.Effects {
+			if now > effect.ExpiresAt || e.Health == nil || e.Health.Current <= 0 {
+				continue
+			}
+			kind := effectKinds[effect.Name]
+			if now >= effect.nextTick {
+				effect.nextTick = now + kind.Interval
+				if kind.Damage > 0 {
+					gs.damage(e, kind.Damage*effect.Stacks)
+				}
+				if kind.Heal > 0 {
+					gs.heal(e, kind.Heal*effect.Stacks)
+				}
+				// resources can go either way, so effects can drain them too
+				for name, amount := range kind.Resources {
+					gs.restore(e, name, amount*effect.Stacks)
+				}
+			}
+			remaining = append(remaining, effect)
+		}
+		e.Actor.Effects = remaining
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// expireTestEffects moves every effect on the actor into the past
+func expireTestEffects(e *entity) {
+	for i := range e.Actor.Effects {
+		e.Actor.Effects[i].ExpiresAt = time.Now().UnixMilli() - 1
+	}
+}
+
+func TestParseEffectKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"valid", `{"poison": {"duration": 100, "damage": 1, "stacking": "stack", "maxStacks": 2}}`, ""},
+		{"no duration", `{"poison": {"damage": 1, "stacking": "refresh"}}`, `effect "poison": duration must be positive`},
+		{"negative damage", `{"poison": {"duration": 100, "damage": -1, "stacking": "refresh"}}`, `effect "poison": damage and heal cannot be negative`},
+		{"too fast", `{"haste": {"duration": 100, "speed": 2, "stacking": "refresh"}}`, `effect "haste": speed must be between 0 and 1`},
+		{"no max stacks", `{"poison": {"duration": 100, "stacking": "stack"}}`, `effect "poison": max stacks must be positive`},
+		{"unknown stacking", `{"poison": {"duration": 100, "stacking": "sometimes"}}`, `effect "poison": unknown stacking "sometimes"`},
+		{"not json", `[`, "parse effects: unexpected end of JSON input"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseEffectKinds([]byte(test.json))
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestEffectStacking(t *testing.T) {
+	tests := []struct {
+		effect string
+		stacks int
+		// extended is whether applying again adds to the time left, rather than restarting it
+		extended bool
+		// restarted is whether applying again sets the time left back to the full duration
+		restarted bool
+	}{
+		{"burning", 1, false, true},
+		{"poison", 2, false, true},
+		{"regeneration", 1, true, false},
+		{"stun", 1, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.effect, func(t *testing.T) {
+			gs := newTestGameState(testPlayer1FacingRight)
+			p, _ := gs.getPlayer("player1")
+			kind := effectKinds[test.effect]
+
+			gs.applyEffect(p, test.effect)
+			require.Len(t, p.Actor.Effects, 1)
+			p.Actor.Effects[0].ExpiresAt -= 50
+			before := p.Actor.Effects[0].ExpiresAt
+
+			gs.applyEffect(p, test.effect)
+			require.Len(t, p.Actor.Effects, 1)
+			effect := p.Actor.Effects[0]
+			require.Equal(t, test.stacks, effect.Stacks)
+			switch {
+			case test.extended:
+				require.Equal(t, before+kind.Duration, effect.ExpiresAt)
+			case test.restarted:
+				require.Greater(t, effect.ExpiresAt, before)
+				require.LessOrEqual(t, effect.ExpiresAt, time.Now().UnixMilli()+kind.Duration)
+			default:
+				require.Equal(t, before, effect.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestEffectMaxStacks(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+	for i := 0; i < 10; i++ {
+		gs.applyEffect(p, "poison")
+	}
+	require.Equal(t, effectKinds["poison"].MaxStacks, p.Actor.Effects[0].Stacks)
+}
+
+func TestDamageOverTime(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+	poison := effectKinds["poison"]
+
+	gs.applyEffect(p, "poison")
+	gs.applyEffect(p, "poison")
+
+	// nothing happens until the first interval has passed
+	effectSystem(gs)
+	require.Equal(t, 100, p.Health.Current)
+
+	p.Actor.Effects[0].nextTick = 0
+	effectSystem(gs)
+	require.Equal(t, 100-2*poison.Damage, p.Health.Current)
+
+	// and then not again until the next one
+	effectSystem(gs)
+	require.Equal(t, 100-2*poison.Damage, p.Health.Current)
+
+	expireTestEffects(p)
+	effectSystem(gs)
+	require.Empty(t, p.Actor.Effects)
+}
+
+func TestRegenerationEffect(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+	p.Health.Current = 50
+	p.Inventory.add("regenDraught", 1)
+
+	gs.playerUseItem("player1", "regenDraught")
+	heal := effectKinds["regeneration"].Heal
+	require.Equal(t, 50, p.Health.Current)
+
+	effectSystem(gs)
+	effectSystem(gs)
+	require.Equal(t, 50+2*heal, p.Health.Current)
+
+	expireTestEffects(p)
+	effectSystem(gs)
+	require.Equal(t, 50+2*heal, p.Health.Current)
+	require.Empty(t, p.Actor.Effects)
+}
+
+func TestSlowEffect(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+
+	gs.applyEffect(p, "slow")
+	gs.playerWalk("player1", "right")
+	require.Equal(t, 1, p.Position.X)
+
+	expireTestEffects(p)
+	effectSystem(gs)
+	gs.playerWalk("player1", "right")
+	require.Equal(t, 3, p.Position.X)
+}
+
+func TestStunLocksActions(t *testing.T) {
+	gs := newTestGameState(
+		testPlayer1FacingRight,
+		player{X: playerSpriteWidth + 5, Y: 0, Name: "player2", Health: 100, Stamina: 100, Facing: "left", Skin: "skin2"},
+	)
+	p, _ := gs.getPlayer("player1")
+
+	gs.applyEffect(p, "stun")
+	gs.playerWalk("player1", "down")
+	gs.playerAttack("player1")
+	gs.playerDodge("player1")
+	require.Equal(t, 0, p.Position.Y)
+	require.False(t, p.Actor.IsAttacking)
+	require.Equal(t, 100, p.resource("stamina"))
+
+	expireTestEffects(p)
+	effectSystem(gs)
+	gs.playerAttack("player1")
+	require.True(t, p.Actor.IsAttacking)
+}
+
+func TestWeaponsApplyEffects(t *testing.T) {
+	hammer := testPlayer1FacingRight
+	hammer.Weapon = "hammer"
+	gs := newTestGameState(
+		hammer,
+		player{X: playerSpriteWidth + 5, Y: 0, Name: "player2", Health: 100, Stamina: 100, Facing: "left", Skin: "skin2"},
+	)
+
+	gs.playerAttack("player1")
+	p2, _ := gs.getPlayer("player2")
+	require.Equal(t, []string{"stun"}, effectNames(p2))
+
+	// the attacker isn't affected by their own hit
+	p1, _ := gs.getPlayer("player1")
+	require.Empty(t, effectNames(p1))
+}
+
+func TestProjectilesApplyEffects(t *testing.T) {
+	weapons["poisonBow"] = weapon{Name: "poisonBow", Damage: 1, Projectile: "arrow", SwingDuration: 100, Effects: []string{"poison"}}
+	defer delete(weapons, "poisonBow")
+
+	gs := newTestGameState(
+		testPlayer1FacingRight,
+		player{X: 100, Y: 0, Name: "player2", Health: 100, Facing: "left", Skin: "skin2"},
+	)
+	// no class can use the test weapon, so hand it over directly
+	p1, _ := gs.getPlayer("player1")
+	p1.Actor.Weapon = "poisonBow"
+
+	gs.playerAttack("player1")
+	for i := 0; i < 10 && len(getTestProjectiles(gs)) > 0; i++ {
+		projectileSystem(gs)
+	}
+	p2, _ := gs.getPlayer("player2")
+	require.Equal(t, []string{"poison"}, effectNames(p2))
+}
+
+func TestHazards(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	gs.Hazards = []hazard{{boundingBox: boundingBox{X: 100, Y: 0, Width: 50, Height: 50}, Effect: "burning"}}
+	p, _ := gs.getPlayer("player1")
+
+	hazardSystem(gs)
+	require.Empty(t, p.Actor.Effects)
+
+	p.Position.X = 100
+	hazardSystem(gs)
+	require.Equal(t, []string{"burning"}, effectNames(p))
+}
+
+func TestEffectsInSnapshot(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	gs.Hazards = []hazard{{boundingBox: boundingBox{X: 100, Y: 0, Width: 50, Height: 50}, Effect: "burning"}}
+	p, _ := gs.getPlayer("player1")
+	gs.applyEffect(p, "slow")
+
+	decoded := struct {
+		Players []player `json:"players"`
+		Hazards []hazard `json:"hazards"`
+	}{}
+	require.NoError(t, json.Unmarshal(gs.toJSON("player1"), &decoded))
+	require.Equal(t, gs.Hazards, decoded.Hazards)
+	require.Len(t, decoded.Players[0].Effects, 1)
+	require.Equal(t, "slow", decoded.Players[0].Effects[0].Name)
+	require.Equal(t, 1, decoded.Players[0].Effects[0].Stacks)
+	require.Equal(t, p.Actor.Effects[0].ExpiresAt, decoded.Players[0].Effects[0].ExpiresAt)
+}
+
+// effectNames returns the names of the effects on the actor
+func effectNames(e *entity) []string {
+	names := []string{}
+	for _, effect := range e.Actor.Effects {
+		names = append(names, effect.Name)
+	}
+	return names
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// hitShape is an area of effect that an attack or ability can hit
+type hitShape interface {
+	overlaps(box boundingBox) bool
+}
+
+// rectHitShape is an axis-aligned rectangle, edges inclusive
+type rectHitShape struct {
+	topLeftCornerX     int
+	topLeftCornerY     int
+	bottomRightCornerX int
+	bottomRightCornerY int
+}
+
+// circleHitShape hits anything within radius of its center
+type circleHitShape struct {
+	centerX int
+	centerY int
+	radius  int
+}
+
+// arcHitShape is a slice of a circle: anything within radius of the center
+// and no more than spread radians either side of direction
+type arcHitShape struct {
+	centerX   int
+	centerY   int
+	radius    int
+	direction float64
+	spread    float64
+}
+
+func (r rectHitShape) overlaps(box boundingBox) bool {
+	return box.X+box.Width >= r.topLeftCornerX && box.X <= r.bottomRightCornerX && box.Y+box.Height >= r.topLeftCornerY && box.Y <= r.bottomRightCornerY
+}
+
+func (c circleHitShape) overlaps(box boundingBox) bool {
+	// find the point in the box closest to the circle center
+	closestX, closestY := closestPointInBox(box, c.centerX, c.centerY)
+	return withinRadius(c.centerX, c.centerY, closestX, closestY, c.radius)
+}
+
+func (a arcHitShape) overlaps(box boundingBox) bool {
+	if a.centerX >= box.X && a.centerX <= box.X+box.Width && a.centerY >= box.Y && a.centerY <= box.Y+box.Height {
+		// the arc starts inside the box
+		return true
+	}
+
+	// check the closest point, the center and the corners of the box
+	// this is an approximation but is exact enough at sprite sizes
+	closestX, closestY := closestPointInBox(box, a.centerX, a.centerY)
+	points := [][2]int{
+		{closestX, closestY},
+		{box.X + box.Width/2, box.Y + box.Height/2},
+		{box.X, box.Y},
+		{box.X + box.Width, box.Y},
+		{box.X, box.Y + box.Height},
+		{box.X + box.Width, box.Y + box.Height},
+	}
+	for _, point := range points {
+		if !withinRadius(a.centerX, a.centerY, point[0], point[1], a.radius) {
+			continue
+		}
+		angle := math.Atan2(float64(point[1]-a.centerY), float64(point[0]-a.centerX))
+		if math.Abs(angleBetween(angle, a.direction)) <= a.spread {
+			return true
+		}
+	}
+	return false
+}
+
+func closestPointInBox(box boundingBox, x, y int) (int, int) {
+	return clamp(x, box.X, box.X+box.Width), clamp(y, box.Y, box.Y+box.Height)
+}
+
+func withinRadius(x1, y1, x2, y2, radius int) bool {
+	dx := x2 - x1
+	dy := y2 - y1
+	return dx*dx+dy*dy <= radius*radius
+}
+
+func clamp(value, lower, upper int) int {
+	if value < lower {
+		return lower
+	}
+	if value > upper {
+		return upper
+	}
+	return value
+}
+
+// angleBetween returns the signed difference between two angles in the range -pi to pi
+func angleBetween(a, b float64) float64 {
+	diff := math.Mod(a-b, 2*math.Pi)
+	if diff > math.Pi {
+		diff -= 2 * math.Pi
+	}
+	if diff < -math.Pi {
+		diff += 2 * math.Pi
+	}
+	return diff
+}
+
+// facingAngle converts a facing direction to an angle in radians
+// y grows downwards, so "down" is a positive angle
+func facingAngle(facing string) float64 {
+	switch facing {
+	case "up":
+		return -math.Pi / 2
+	case "down":
+		return math.Pi / 2
+	case "left":
+		return math.Pi
+	}
+	return 0
+}
+
+// entitiesHitByShape returns every entity with health overlapping the shape,
+// nearest to the attacker first. The attacker, dodging actors and anyone the attacker can't hurt are never hit.
+// maxTargets of 0 means there is no limit.
+func (gs *gameState) entitiesHitByShape(attacker *entity, shape hitShape, maxTargets int) []*entity {
+	attackerSprite := attacker.spriteBox()
+	attackerCenterX := attackerSprite.X + attackerSprite.Width/2
+	attackerCenterY := attackerSprite.Y + attackerSprite.Height/2
+
+	type target struct {
+		entity   *entity
+		distance int
+	}
+	targets := []target{}
+	for _, e := range gs.entities {
+		if e.ID == attacker.ID {
+			continue
+		}
+		if e.Health == nil || e.Sprite == nil || e.isDodging() || e.isDead() {
+			continue
+		}
+		if attacker.Actor != nil && !gs.canHurt(attacker.Actor.Team, e) {
+			continue
+		}
+
+		sprite := e.spriteBox()
+		if !shape.overlaps(sprite) {
+			continue
+		}
+
+		dx := sprite.X + sprite.Width/2 - attackerCenterX
+		dy := sprite.Y + sprite.Height/2 - attackerCenterY
+		targets = append(targets, target{entity: e, distance: dx*dx + dy*dy})
+	}
+
+	// stable sort keeps entity order between targets at the same distance
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].distance < targets[j].distance
+	})
+	if maxTargets > 0 && len(targets) > maxTargets {
+		targets = targets[:maxTargets]
+	}
+
+	hit := []*entity{}
+	for _, t := range targets {
+		hit = append(hit, t.entity)
+	}
+	return hit
+}
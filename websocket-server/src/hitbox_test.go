@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var hitShapeTestCases = []struct {
+	Name     string
+	Shape    hitShape
+	Box      boundingBox
+	expected bool
+}{
+	{
+		Name:     "rectTouchingEdge",
+		Shape:    rectHitShape{topLeftCornerX: 0, topLeftCornerY: 0, bottomRightCornerX: 10, bottomRightCornerY: 10},
+		Box:      boundingBox{X: 10, Y: 10, Width: 5, Height: 5},
+		expected: true,
+	},
+	{
+		Name:     "rectOutside",
+		Shape:    rectHitShape{topLeftCornerX: 0, topLeftCornerY: 0, bottomRightCornerX: 10, bottomRightCornerY: 10},
+		Box:      boundingBox{X: 11, Y: 0, Width: 5, Height: 5},
+		expected: false,
+	},
+	{
+		Name:     "circleOverlappingSide",
+		Shape:    circleHitShape{centerX: 0, centerY: 0, radius: 20},
+		Box:      boundingBox{X: 20, Y: -5, Width: 10, Height: 10},
+		expected: true,
+	},
+	{
+		Name:     "circleMissingCorner",
+		Shape:    circleHitShape{centerX: 0, centerY: 0, radius: 20},
+		Box:      boundingBox{X: 15, Y: 15, Width: 10, Height: 10},
+		expected: false,
+	},
+	{
+		Name:     "arcInFront",
+		Shape:    arcHitShape{centerX: 0, centerY: 0, radius: 30, direction: facingAngle("right"), spread: math.Pi / 4},
+		Box:      boundingBox{X: 10, Y: -5, Width: 10, Height: 10},
+		expected: true,
+	},
+	{
+		Name:     "arcBehind",
+		Shape:    arcHitShape{centerX: 0, centerY: 0, radius: 30, direction: facingAngle("right"), spread: math.Pi / 4},
+		Box:      boundingBox{X: -20, Y: -5, Width: 10, Height: 10},
+		expected: false,
+	},
+	{
+		Name:     "arcToTheSide",
+		Shape:    arcHitShape{centerX: 0, centerY: 0, radius: 30, direction: facingAngle("up"), spread: math.Pi / 4},
+		Box:      boundingBox{X: 15, Y: -5, Width: 10, Height: 10},
+		expected: false,
+	},
+	{
+		Name:     "arcContainingCenter",
+		Shape:    arcHitShape{centerX: 0, centerY: 0, radius: 5, direction: facingAngle("left"), spread: math.Pi / 4},
+		Box:      boundingBox{X: -5, Y: -5, Width: 10, Height: 10},
+		expected: true,
+	},
+}
+
+func TestHitShapeOverlaps(t *testing.T) {
+	for _, tc := range hitShapeTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.Shape.overlaps(tc.Box))
+		})
+	}
+}
+
+func TestPlayersHitByCircle(t *testing.T) {
+	gs := newTestGameState(
+		testPlayer1FacingRight,
+		player{X: 0, Y: playerSpriteHeight + 5, Name: "player2", Health: 100, Facing: "up", Skin: "skin2"},
+		player{X: -playerSpriteWidth - 5, Y: 0, Name: "player3", Health: 100, Facing: "right", Skin: "skin2"},
+		player{X: 200, Y: 200, Name: "player4", Health: 100, Facing: "left", Skin: "skin2"},
+	)
+	attacker, _ := gs.getPlayer("player1")
+
+	// a circle around player1 hits players on every side of them
+	shape := circleHitShape{centerX: playerSpriteWidth / 2, centerY: playerSpriteHeight / 2, radius: playerSpriteWidth}
+	require.Equal(t, []string{"player2", "player3"}, entityNames(gs.entitiesHitByShape(attacker, shape, 0)))
+}
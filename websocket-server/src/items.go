@@ -0,0 +1,341 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+// inventorySize is the number of slots in a player's inventory
+const inventorySize = 10
+
+//go:embed items.json
+var defaultItemsJSON []byte
+
+// item kinds that can be picked up, replaced at startup if an items file is given
+var itemKinds = mustParseItemKinds(defaultItemsJSON)
+
+type itemKind struct {
+	Name string `json:"-"`
+	// MaxStack is how many of the item fit in one inventory slot
+	MaxStack int `json:"maxStack"`
+	Width    int `json:"width"`
+	Height   int `json:"height"`
+	// Weapon makes using the item equip the named weapon
+	Weapon string `json:"weapon"`
+	// Consumable items are used up, restoring health and resources and applying their effects
+	Consumable bool           `json:"consumable"`
+	Health     int            `json:"health"`
+	Resources  map[string]int `json:"resources"`
+	Effects    []string       `json:"effects"`
+	// UseDuration is how long using the item takes in milliseconds, the actor can't do anything else meanwhile
+	UseDuration int64 `json:"useDuration"`
+}
+
+// itemComponent is an item lying in the world, waiting to be picked up
+type itemComponent struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	// droppedBy can't pick the item back up until they have walked off it
+	droppedBy entityID
+}
+
+type inventorySlot struct {
+	Item  string `json:"item"`
+	Count int    `json:"count"`
+}
+
+// inventoryComponent is what an actor is carrying
+// it is left out of the entity's json so only its owner gets to see it
+type inventoryComponent struct {
+	Slots []inventorySlot
+}
+
+func loadItemKinds(path string) (map[string]itemKind, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read items: %w", err)
+	}
+	return parseItemKinds(data)
+}
+
+func mustParseItemKinds(data []byte) map[string]itemKind {
+	parsed, err := parseItemKinds(data)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func parseItemKinds(data []byte) (map[string]itemKind, error) {
+	parsed := map[string]itemKind{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("parse items: %w", err)
+	}
+
+	names := []string{}
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		k := parsed[name]
+		k.Name = name
+		err := k.validate()
+		if err != nil {
+			return nil, fmt.Errorf("item %q: %w", name, err)
+		}
+		parsed[name] = k
+	}
+
+	return parsed, nil
+}
+
+func (k itemKind) validate() error {
+	if k.MaxStack <= 0 {
+		return fmt.Errorf("max stack must be positive")
+	}
+	if k.Width <= 0 || k.Height <= 0 {
+		return fmt.Errorf("size must be positive")
+	}
+	if k.UseDuration < 0 {
+		return fmt.Errorf("use duration cannot be negative")
+	}
+	if k.Weapon != "" {
+		if _, ok := weapons[k.Weapon]; !ok {
+			return fmt.Errorf("unknown weapon %q", k.Weapon)
+		}
+		if k.Consumable {
+			return fmt.Errorf("weapons cannot be consumable")
+		}
+	}
+
+	if !k.Consumable {
+		if k.Health != 0 || len(k.Resources) > 0 || len(k.Effects) > 0 {
+			return fmt.Errorf("only consumables can restore health or resources, or apply effects")
+		}
+		return nil
+	}
+	if k.Health < 0 {
+		return fmt.Errorf("health cannot be negative")
+	}
+	for _, name := range sortedKeys(k.Resources) {
+		if k.Resources[name] < 0 {
+			return fmt.Errorf("%s cannot be negative", name)
+		}
+	}
+	if err := validateEffects(k.Effects); err != nil {
+		return err
+	}
+	if k.Health == 0 && len(k.Resources) == 0 && len(k.Effects) == 0 {
+		return fmt.Errorf("consumables must restore health or resources, or apply an effect")
+	}
+	return nil
+}
+
+// spawnItem puts count of the named item in the world at x, y
+func (gs *gameState) spawnItem(name string, count, x, y int) (*entity, error) {
+	kind, ok := itemKinds[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown item %q", name)
+	}
+	return gs.addEntity(&entity{
+		Kind:     "item",
+		Position: &positionComponent{X: x, Y: y},
+		Hitbox:   &hitboxComponent{Width: kind.Width, Height: kind.Height},
+		Sprite:   &spriteComponent{Width: kind.Width, Height: kind.Height, Skin: kind.Name},
+		Item:     &itemComponent{Name: kind.Name, Count: count},
+	}), nil
+}
+
+// add puts up to count of the item in the inventory, topping up existing stacks first
+// it returns how many fit
+func (inv *inventoryComponent) add(name string, count int) int {
+	kind, ok := itemKinds[name]
+	if !ok {
+		return 0
+	}
+
+	added := 0
+	for i := range inv.Slots {
+		if added == count {
+			break
+		}
+		slot := &inv.Slots[i]
+		if slot.Item != name {
+			continue
+		}
+		n := min(kind.MaxStack-slot.Count, count-added)
+		if n > 0 {
+			slot.Count += n
+			added += n
+		}
+	}
+	for added < count && len(inv.Slots) < inventorySize {
+		n := min(kind.MaxStack, count-added)
+		inv.Slots = append(inv.Slots, inventorySlot{Item: name, Count: n})
+		added += n
+	}
+	return added
+}
+
+// remove takes up to count of the item out of the inventory, emptying the last stacks first
+// it returns how many were removed
+func (inv *inventoryComponent) remove(name string, count int) int {
+	removed := 0
+	for i := len(inv.Slots) - 1; i >= 0 && removed < count; i-- {
+		slot := &inv.Slots[i]
+		if slot.Item != name {
+			continue
+		}
+		n := min(slot.Count, count-removed)
+		slot.Count -= n
+		removed += n
+	}
+
+	// drop the empty slots
+	remaining := []inventorySlot{}
+	for _, slot := range inv.Slots {
+		if slot.Count > 0 {
+			remaining = append(remaining, slot)
+		}
+	}
+	inv.Slots = remaining
+	return removed
+}
+
+func (inv *inventoryComponent) count(name string) int {
+	total := 0
+	for _, slot := range inv.Slots {
+		if slot.Item == name {
+			total += slot.Count
+		}
+	}
+	return total
+}
+
+// pickupSystem moves items into the inventory of whoever is standing on them
+func pickupSystem(gs *gameState) {
+	for _, item := range gs.entities {
+		if item.Item == nil {
+			continue
+		}
+		itemBox := item.hitboxBox()
+		for _, e := range gs.entities {
+			if e.Inventory == nil || e.Hitbox == nil {
+				continue
+			}
+			if !boxesOverlap(itemBox, e.hitboxBox()) {
+				if item.Item.droppedBy == e.ID {
+					// they walked off it, so they can pick it up again
+					item.Item.droppedBy = 0
+				}
+				continue
+			}
+			if item.Item.droppedBy == e.ID {
+				continue
+			}
+
+			item.Item.Count -= e.Inventory.add(item.Item.Name, item.Item.Count)
+			if item.Item.Count <= 0 {
+				gs.removeEntity(item.ID)
+				break
+			}
+		}
+	}
+}
+
+func (gs *gameState) playerUseItem(name, item string) {
+	p, err := gs.getPlayer(name)
+	if err != nil {
+		log.Println("cannot find player using item")
+		return
+	}
+	gs.useItem(p, item)
+}
+
+// useItem uses one of the item from the actor's inventory
+func (gs *gameState) useItem(e *entity, name string) {
+	if e.Inventory == nil || e.Inventory.count(name) == 0 {
+		return
+	}
+
+	// can't use anything else until the current item is finished with, or mid swing
+	if e.isBusy() || e.Actor.IsAttacking {
+		return
+	}
+
+	kind := itemKinds[name]
+	if kind.Weapon != "" {
+		if !e.canWield(kind.Weapon) {
+			return
+		}
+		// weapons stay in the inventory so players can switch back and forth
+		e.Actor.Weapon = kind.Weapon
+	}
+
+	if kind.Consumable {
+		e.Inventory.remove(name, 1)
+		if kind.Health > 0 {
+			gs.heal(e, kind.Health)
+		}
+		for name, amount := range kind.Resources {
+			gs.restore(e, name, amount)
+		}
+		for _, effect := range kind.Effects {
+			gs.applyEffect(e, effect)
+		}
+	}
+
+	if kind.UseDuration > 0 {
+		e.Actor.IsUsing = true
+		e.Actor.IsWalking = false
+		e.Actor.lastUse = gs.now()
+		e.Actor.useDuration = kind.UseDuration
+		e.Actor.path = nil
+	}
+}
+
+func (gs *gameState) playerDropItem(name, item string, count int) {
+	p, err := gs.getPlayer(name)
+	if err != nil {
+		log.Println("cannot find player dropping item")
+		return
+	}
+	gs.dropItem(p, item, count)
+}
+
+// dropItem puts up to count of the item from the actor's inventory on the ground beneath them
+func (gs *gameState) dropItem(e *entity, name string, count int) {
+	if e.Inventory == nil {
+		return
+	}
+	if count <= 0 {
+		count = 1
+	}
+
+	dropped := e.Inventory.remove(name, count)
+	if dropped == 0 {
+		return
+	}
+
+	// dropping the equipped weapon goes back to the one they started with
+	kind := itemKinds[name]
+	if kind.Weapon != "" && kind.Weapon == e.Actor.Weapon && e.Inventory.count(name) == 0 {
+		e.Actor.Weapon = e.baseWeapon()
+	}
+
+	hitbox := e.hitboxBox()
+	x := hitbox.X + (hitbox.Width-kind.Width)/2
+	y := hitbox.Y + (hitbox.Height-kind.Height)/2
+	item, err := gs.spawnItem(name, dropped, x, y)
+	if err != nil {
+		log.Println("drop item:", err)
+		return
+	}
+	item.Item.droppedBy = e.ID
+}
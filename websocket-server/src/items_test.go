@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// getTestItems returns every item lying in the world
+func getTestItems(gs *gameState) []*entity {
+	items := []*entity{}
+	for _, e := range gs.entities {
+		if e.Item != nil {
+			items = append(items, e)
+		}
+	}
+	return items
+}
+
+func TestParseItemKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"valid", `{"coin": {"maxStack": 10, "width": 8, "height": 8}}`, ""},
+		{"weapon", `{"bow": {"maxStack": 1, "width": 8, "height": 8, "weapon": "bow"}}`, ""},
+		{"no stack", `{"coin": {"width": 8, "height": 8}}`, `item "coin": max stack must be positive`},
+		{"no size", `{"coin": {"maxStack": 10}}`, `item "coin": size must be positive`},
+		{"unknown weapon", `{"axe": {"maxStack": 1, "width": 8, "height": 8, "weapon": "axe"}}`, `item "axe": unknown weapon "axe"`},
+		{"potion", `{"potion": {"maxStack": 5, "width": 8, "height": 8, "consumable": true, "health": 10}}`, ""},
+		{"consumable weapon", `{"bow": {"maxStack": 1, "width": 8, "height": 8, "weapon": "bow", "consumable": true, "health": 10}}`, `item "bow": weapons cannot be consumable`},
+		{"heals without consuming", `{"potion": {"maxStack": 5, "width": 8, "height": 8, "health": 10}}`, `item "potion": only consumables can restore health or resources, or apply effects`},
+		{"does nothing", `{"potion": {"maxStack": 5, "width": 8, "height": 8, "consumable": true}}`, `item "potion": consumables must restore health or resources, or apply an effect`},
+		{"unknown effect", `{"potion": {"maxStack": 5, "width": 8, "height": 8, "consumable": true, "effects": ["unknown"]}}`, `item "potion": unknown effect "unknown"`},
+		{"negative use duration", `{"coin": {"maxStack": 5, "width": 8, "height": 8, "useDuration": -1}}`, `item "coin": use duration cannot be negative`},
+		{"not json", `[`, "parse items: unexpected end of JSON input"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseItemKinds([]byte(test.json))
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestInventoryStackLimits(t *testing.T) {
+	inv := &inventoryComponent{}
+
+	// coins stack up to 99 per slot
+	require.Equal(t, 150, inv.add("coin", 150))
+	require.Equal(t, []inventorySlot{{Item: "coin", Count: 99}, {Item: "coin", Count: 51}}, inv.Slots)
+
+	// weapons don't stack
+	require.Equal(t, 2, inv.add("bow", 2))
+	require.Len(t, inv.Slots, 4)
+
+	// only as much as fits in the remaining slots is added
+	added := inv.add("coin", 99*inventorySize)
+	require.Equal(t, 48+99*(inventorySize-4), added)
+	require.Len(t, inv.Slots, inventorySize)
+	require.Equal(t, 0, inv.add("dagger", 1))
+	require.Equal(t, 0, inv.add("unknown", 1))
+
+	// removing empties the last stacks first, and frees their slots
+	require.Equal(t, 100, inv.remove("coin", 100))
+	require.Len(t, inv.Slots, inventorySize-1)
+	require.Equal(t, 2, inv.remove("bow", 5))
+	require.Equal(t, 0, inv.count("bow"))
+}
+
+func TestPickupItem(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+	hitbox := p.hitboxBox()
+
+	_, err := gs.spawnItem("coin", 5, hitbox.X+hitbox.Width+4, hitbox.Y)
+	require.NoError(t, err)
+
+	// not touching yet
+	pickupSystem(gs)
+	require.Len(t, getTestItems(gs), 1)
+
+	gs.playerWalk("player1", "right")
+	gs.playerWalk("player1", "right")
+	pickupSystem(gs)
+	require.Empty(t, getTestItems(gs))
+	require.Equal(t, 5, p.Inventory.count("coin"))
+}
+
+func TestPickupLeavesWhatDoesNotFit(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+	hitbox := p.hitboxBox()
+	for i := 0; i < inventorySize; i++ {
+		p.Inventory.add("coin", 99)
+	}
+	p.Inventory.remove("coin", 1)
+
+	_, err := gs.spawnItem("coin", 5, hitbox.X, hitbox.Y)
+	require.NoError(t, err)
+	pickupSystem(gs)
+
+	items := getTestItems(gs)
+	require.Len(t, items, 1)
+	require.Equal(t, 4, items[0].Item.Count)
+	require.Equal(t, 99*inventorySize, p.Inventory.count("coin"))
+}
+
+func TestUseAndDropItems(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+
+	// can't use what you don't have
+	gs.handleEvent(gameEvent{Type: "use", Data: eventData{player: player{Name: "player1"}, Item: "bow"}})
+	require.Equal(t, defaultWeapon, p.Actor.Weapon)
+
+	p.Inventory.add("bow", 1)
+	p.Inventory.add("coin", 10)
+	gs.handleEvent(gameEvent{Type: "use", Data: eventData{player: player{Name: "player1"}, Item: "bow"}})
+	require.Equal(t, "bow", p.Actor.Weapon)
+	require.Equal(t, 1, p.Inventory.count("bow"))
+
+	gs.handleEvent(gameEvent{Type: "drop", Data: eventData{player: player{Name: "player1"}, Item: "coin", Count: 3}})
+	require.Equal(t, 7, p.Inventory.count("coin"))
+	items := getTestItems(gs)
+	require.Len(t, items, 1)
+	require.Equal(t, itemComponent{Name: "coin", Count: 3, droppedBy: p.ID}, *items[0].Item)
+
+	// the dropper doesn't pick it straight back up
+	pickupSystem(gs)
+	require.Len(t, getTestItems(gs), 1)
+
+	// dropping the equipped weapon switches back to the default one
+	gs.handleEvent(gameEvent{Type: "drop", Data: eventData{player: player{Name: "player1"}, Item: "bow"}})
+	require.Equal(t, defaultWeapon, p.Actor.Weapon)
+	require.Len(t, getTestItems(gs), 2)
+
+	// once they walk off the items, walking back picks them up again
+	for i := 0; i < 20; i++ {
+		gs.playerWalk("player1", "right")
+	}
+	pickupSystem(gs)
+	require.Len(t, getTestItems(gs), 2)
+	for i := 0; i < 20; i++ {
+		gs.playerWalk("player1", "left")
+	}
+	pickupSystem(gs)
+	require.Empty(t, getTestItems(gs))
+	require.Equal(t, 10, p.Inventory.count("coin"))
+	require.Equal(t, 1, p.Inventory.count("bow"))
+}
+
+func TestSnapshotOnlyIncludesOwnInventory(t *testing.T) {
+	gs := newTestGameState(
+		testPlayer1FacingRight,
+		player{X: 100, Y: 0, Name: "player2", Health: 100, Facing: "left", Skin: "skin2"},
+	)
+	p1, _ := gs.getPlayer("player1")
+	p1.Inventory.add("coin", 3)
+	p2, _ := gs.getPlayer("player2")
+	p2.Inventory.add("bow", 1)
+
+	decoded := struct {
+		Inventory []inventorySlot  `json:"inventory"`
+		Entities  []map[string]any `json:"entities"`
+	}{}
+	require.NoError(t, json.Unmarshal(gs.toJSON("player1"), &decoded))
+	require.Equal(t, []inventorySlot{{Item: "coin", Count: 3}}, decoded.Inventory)
+	for _, e := range decoded.Entities {
+		require.NotContains(t, e, "inventory")
+	}
+
+	require.NoError(t, json.Unmarshal(gs.toJSON("player2"), &decoded))
+	require.Equal(t, []inventorySlot{{Item: "bow", Count: 1}}, decoded.Inventory)
+
+	decoded.Inventory = nil
+	require.NoError(t, json.Unmarshal(gs.toJSON(""), &decoded))
+	require.Nil(t, decoded.Inventory)
+}
+
+func TestEventDataIncludesItems(t *testing.T) {
+	event := gameEvent{}
+	err := json.Unmarshal([]byte(`{"type": "drop", "data": {"name": "player1", "item": "coin", "count": 2}}`), &event)
+	require.NoError(t, err)
+	require.Equal(t, "player1", event.Data.Name)
+	require.Equal(t, "coin", event.Data.Item)
+	require.Equal(t, 2, event.Data.Count)
+}
+
+func TestUseConsumables(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+	p.Health.Current = 30
+	p.Actor.Resources["stamina"].Current = 10
+	p.Inventory.add("healthPotion", 2)
+	p.Inventory.add("staminaTonic", 1)
+
+	gs.playerUseItem("player1", "healthPotion")
+	require.Equal(t, 30+itemKinds["healthPotion"].Health, p.Health.Current)
+	require.Equal(t, 1, p.Inventory.count("healthPotion"))
+	require.True(t, getTestPlayer(t, gs, "player1").IsUsing)
+
+	// drinking locks every other action until it's finished
+	gs.playerUseItem("player1", "staminaTonic")
+	gs.playerWalk("player1", "right")
+	gs.playerAttack("player1")
+	gs.playerDodge("player1")
+	require.Equal(t, 10, p.resource("stamina"))
+	require.Equal(t, 0, p.Position.X)
+	require.False(t, p.Actor.IsAttacking)
+	require.Equal(t, 1, p.Inventory.count("staminaTonic"))
+
+	p.Actor.lastUse -= itemKinds["healthPotion"].UseDuration + 1
+	actionTimeoutSystem(gs)
+	require.False(t, p.Actor.IsUsing)
+
+	// health never goes above the max
+	gs.playerUseItem("player1", "healthPotion")
+	require.Equal(t, p.Health.Max, p.Health.Current)
+	require.Equal(t, 0, p.Inventory.count("healthPotion"))
+	p.Actor.IsUsing = false
+
+	gs.playerUseItem("player1", "staminaTonic")
+	require.Equal(t, 10+itemKinds["staminaTonic"].Resources["stamina"], p.resource("stamina"))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// limits on what hosts can set, so a room can't be made unplayable
+const (
+	maxScoreLimit = 1000
+	maxTimeLimit  = 60 * 60 * 1000
+	maxMinPlayers = 16
+)
+
+// settingsChange is the settings a host asks for, anything left out stays as it is
+// backfill and bot difficulty are for operators, so hosts can't change them
+type settingsChange struct {
+	FriendlyFire *bool   `json:"friendlyFire"`
+	Mode         *string `json:"mode"`
+	ScoreLimit   *int    `json:"scoreLimit"`
+	TimeLimit    *int64  `json:"timeLimit"`
+	MinPlayers   *int    `json:"minPlayers"`
+}
+
+func (c settingsChange) validate() error {
+	if c.Mode != nil {
+		if _, ok := gameModes[*c.Mode]; !ok {
+			return fmt.Errorf("unknown game mode %q", *c.Mode)
+		}
+	}
+	if c.ScoreLimit != nil && (*c.ScoreLimit < 0 || *c.ScoreLimit > maxScoreLimit) {
+		return fmt.Errorf("score limit must be between 0 and %d", maxScoreLimit)
+	}
+	if c.TimeLimit != nil && (*c.TimeLimit < 0 || *c.TimeLimit > maxTimeLimit) {
+		return fmt.Errorf("time limit must be between 0 and %d", maxTimeLimit)
+	}
+	if c.MinPlayers != nil && (*c.MinPlayers < 0 || *c.MinPlayers > maxMinPlayers) {
+		return fmt.Errorf("min players must be between 0 and %d", maxMinPlayers)
+	}
+	return nil
+}
+
+// inLobby reports whether the room is waiting for a match to start,
+// players can change their class, skin and team and nobody can be hurt
+func (gs *gameState) inLobby() bool {
+	return gs.Match != nil && (gs.Match.Phase == phaseWarmup || gs.Match.Phase == phaseCountdown)
+}
+
+// readyCount is how many players in the room are ready
+func (gs *gameState) readyCount() int {
+	count := 0
+	for _, e := range gs.entities {
+		if e.Kind == "player" && gs.Match.Ready[e.Actor.Name] {
+			count++
+		}
+	}
+	return count
+}
+
+// canStart reports whether the countdown can run, because enough players are ready or the host started it
+func (gs *gameState) canStart() bool {
+	if gs.Match.forceStart {
+		return gs.playerCount() > 0
+	}
+	return gs.readyCount() >= gs.minPlayers()
+}
+
+// joinLobby makes the player host if nobody else is
+func (gs *gameState) joinLobby(name string) {
+	if gs.Match != nil && gs.Match.Host == "" {
+		gs.Match.Host = name
+	}
+}
+
+// leaveLobby forgets the player is ready, and hands host to whoever has been in the room longest
+// bots are never host
+func (gs *gameState) leaveLobby(name string) {
+	if gs.Match == nil {
+		return
+	}
+	delete(gs.Match.Ready, name)
+	if gs.Match.Host != name {
+		return
+	}
+	gs.Match.Host = ""
+	for _, e := range gs.entities {
+		if e.Kind == "player" && e.Actor.Name != name && !e.isBot() {
+			gs.Match.Host = e.Actor.Name
+			return
+		}
+	}
+}
+
+// isHost reports whether the named player runs the room
+func (gs *gameState) isHost(name string) bool {
+	return gs.Match != nil && name != "" && gs.Match.Host == name
+}
+
+func (gs *gameState) playerReady(name string, ready bool) {
+	if !gs.inLobby() {
+		return
+	}
+	if _, err := gs.getPlayer(name); err != nil {
+		log.Println("cannot find player getting ready")
+		return
+	}
+	gs.Match.Ready[name] = ready
+}
+
+// playerChoose changes the player's class, skin and team while they wait in the lobby
+// anything left empty is kept as it is
+func (gs *gameState) playerChoose(p player) {
+	if !gs.inLobby() {
+		return
+	}
+	e, err := gs.getPlayer(p.Name)
+	if err != nil {
+		log.Println("cannot find player choosing")
+		return
+	}
+
+	if p.Class != "" {
+		e.applyClass(p.Class)
+	}
+	if p.Skin != "" {
+		e.Sprite.Skin = p.Skin
+	}
+	if t, ok := gs.getTeam(p.Team); ok && t.Name != e.Actor.Team {
+		e.Actor.Team = t.Name
+		gs.spawnInArea(e, t.Spawn)
+	}
+}
+
+// hostStart starts the countdown without waiting for everyone to be ready
+func (gs *gameState) hostStart(name string) {
+	if !gs.isHost(name) || gs.Match.Phase != phaseWarmup {
+		return
+	}
+	gs.Match.forceStart = true
+	gs.setPhase(phaseCountdown, countdownDuration)
+}
+
+// hostKick removes another player from the room
+func (gs *gameState) hostKick(name, target string) {
+	if !gs.isHost(name) || target == name {
+		return
+	}
+	gs.removePlayer(target)
+}
+
+// hostSettings changes the rules the room plays by, between matches
+// the change is only made if every setting in it is allowed
+func (gs *gameState) hostSettings(name string, change settingsChange) {
+	if !gs.isHost(name) || !gs.inLobby() {
+		return
+	}
+	err := change.validate()
+	if err != nil {
+		log.Println("host settings:", err)
+		gs.systemMessage(name, err.Error())
+		return
+	}
+	if change.Mode != nil && *change.Mode != gs.Settings.Mode {
+		// a known mode can always be set
+		gs.setMode(*change.Mode)
+	}
+	if change.FriendlyFire != nil {
+		gs.Settings.FriendlyFire = *change.FriendlyFire
+	}
+	if change.ScoreLimit != nil {
+		gs.Settings.ScoreLimit = *change.ScoreLimit
+	}
+	if change.TimeLimit != nil {
+		gs.Settings.TimeLimit = *change.TimeLimit
+	}
+	if change.MinPlayers != nil {
+		gs.Settings.MinPlayers = *change.MinPlayers
+	}
+	// a change of rules needs everyone to agree again
+	gs.setPhase(phaseWarmup, 0)
+	gs.Match.forceStart = false
+}
+
+// assignTeams puts every player on a team when the room has teams, and takes them off when it doesn't
+func (gs *gameState) assignTeams() {
+	for _, e := range gs.entities {
+		if e.Kind != "player" {
+			continue
+		}
+		if len(gs.Teams) == 0 {
+			e.Actor.Team = ""
+			continue
+		}
+		if _, ok := gs.getTeam(e.Actor.Team); ok {
+			continue
+		}
+		// leave them off while picking, so they don't count towards the team sizes
+		e.Actor.Team = ""
+		t, _ := gs.selectTeam("")
+		e.Actor.Team = t.Name
+		gs.spawnInArea(e, t.Spawn)
+	}
+}
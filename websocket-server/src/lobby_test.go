@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLobbyHost(t *testing.T) {
+	gs := newTestMatch(t, "deathmatch",
+		player{Name: "player1", Facing: "down"},
+		player{X: 100, Name: "player2", Facing: "down"},
+		player{X: 200, Name: "player3", Facing: "down"},
+	)
+	require.Equal(t, "player1", gs.Match.Host)
+
+	// only the host can kick
+	gs.handleEvent(gameEvent{Type: "kick", Data: eventData{player: player{Name: "player2"}, Target: "player3"}})
+	require.Equal(t, 3, gs.playerCount())
+	gs.handleEvent(gameEvent{Type: "kick", Data: eventData{player: player{Name: "player1"}, Target: "player3"}})
+	require.Equal(t, 2, gs.playerCount())
+
+	// host passes on to whoever has been in the room longest
+	gs.handleEvent(gameEvent{Type: "leave", Data: eventData{player: player{Name: "player1"}}})
+	require.Equal(t, "player2", gs.Match.Host)
+}
+
+func TestLobbyHostStart(t *testing.T) {
+	gs := newTestMatch(t, "deathmatch",
+		player{Name: "player1", Facing: "down"},
+		player{X: 100, Name: "player2", Facing: "down"},
+	)
+
+	gs.handleEvent(gameEvent{Type: "start", Data: eventData{player: player{Name: "player2"}}})
+	require.Equal(t, phaseWarmup, gs.Match.Phase)
+
+	// the host doesn't have to wait for anyone to be ready
+	gs.handleEvent(gameEvent{Type: "start", Data: eventData{player: player{Name: "player1"}}})
+	gs.handleEvent(gameEvent{Type: "refresh"})
+	require.Equal(t, phaseCountdown, gs.Match.Phase)
+	endTestPhase(gs)
+	gs.handleEvent(gameEvent{Type: "refresh"})
+	require.Equal(t, phaseInProgress, gs.Match.Phase)
+}
+
+// ptr returns a pointer to the value, for settings changes
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func TestLobbySettings(t *testing.T) {
+	gs := newTestMatch(t, "deathmatch",
+		player{Name: "player1", Facing: "down"},
+		player{X: 100, Name: "player2", Facing: "down"},
+	)
+	gs.Settings.Backfill = 2
+	gs.Settings.TimeLimit = 60000
+	gs.playerReady("player2", true)
+
+	change := settingsChange{Mode: ptr("teamDeathmatch"), ScoreLimit: ptr(10), MinPlayers: ptr(4)}
+	gs.handleEvent(gameEvent{Type: "settings", Data: eventData{player: player{Name: "player2"}, Settings: change}})
+	require.Equal(t, "deathmatch", gs.Settings.Mode)
+
+	// settings left out of the change are kept
+	gs.handleEvent(gameEvent{Type: "settings", Data: eventData{player: player{Name: "player1"}, Settings: change}})
+	expected := roomSettings{Mode: "teamDeathmatch", ScoreLimit: 10, TimeLimit: 60000, MinPlayers: 4, Backfill: 2}
+	require.Equal(t, expected, gs.Settings)
+	require.Equal(t, "player1", gs.Match.Host)
+	require.Empty(t, gs.Match.Ready)
+
+	// everyone is put on a team for the new mode
+	require.Equal(t, "red", getTestPlayer(t, gs, "player1").Team)
+	require.Equal(t, "blue", getTestPlayer(t, gs, "player2").Team)
+
+	// changes with anything out of range are ignored as a whole
+	for _, invalid := range []settingsChange{
+		{Mode: ptr("tag")},
+		{Mode: ptr("")},
+		{ScoreLimit: ptr(-1)},
+		{ScoreLimit: ptr(maxScoreLimit + 1), FriendlyFire: ptr(true)},
+		{TimeLimit: ptr(int64(maxTimeLimit + 1))},
+		{MinPlayers: ptr(maxMinPlayers + 1)},
+	} {
+		gs.handleEvent(gameEvent{Type: "settings", Data: eventData{player: player{Name: "player1"}, Settings: invalid}})
+		require.Equal(t, expected, gs.Settings)
+	}
+}
+
+func TestLobbyChoose(t *testing.T) {
+	gs := newTestMatch(t, "teamDeathmatch",
+		player{Name: "player1", Team: "red", Facing: "down"},
+		player{Name: "player2", Team: "blue", Facing: "down"},
+	)
+
+	gs.handleEvent(gameEvent{Type: "choose", Data: eventData{player: player{Name: "player1", Class: "mage", Skin: "skin3", Team: "blue"}}})
+	p := getTestPlayer(t, gs, "player1")
+	require.Equal(t, "mage", p.Class)
+	require.Equal(t, "skin3", p.Skin)
+	require.Equal(t, "blue", p.Team)
+	require.Equal(t, classes["mage"].Health, p.Health)
+
+	// nobody can be hurt in the lobby
+	p1, _ := gs.getPlayer("player1")
+	require.False(t, gs.canHurt("red", p1))
+
+	// choices are locked once the match starts
+	startTestMatch(gs)
+	gs.handleEvent(gameEvent{Type: "choose", Data: eventData{player: player{Name: "player1", Class: "rogue"}}})
+	require.Equal(t, "mage", getTestPlayer(t, gs, "player1").Class)
+	require.True(t, gs.canHurt("red", p1))
+}
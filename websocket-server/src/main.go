@@ -1,23 +1,234 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	flag.Parse()
 	var addr = flag.String("addr", ":8181", "http service address")
+	var weaponsPath = flag.String("weapons", "", "path to a weapons definition file, defaults to the built in weapons")
+	var effectsPath = flag.String("effects", "", "path to a status effects definition file, defaults to the built in effects")
+	var projectilesPath = flag.String("projectiles", "", "path to a projectiles definition file, defaults to the built in projectiles")
+	var behaviorsPath = flag.String("behaviors", "", "path to an npc behavior tree file, defaults to the built in behaviors")
+	var classesPath = flag.String("classes", "", "path to a classes definition file, defaults to the built in classes")
+	var itemsPath = flag.String("items", "", "path to an items definition file, defaults to the built in items")
+	var spawnersPath = flag.String("spawners", "", "path to an item spawners file placing items in every room, defaults to the built in spawners")
+	var enemies = flag.Int("enemies", 0, "number of npc enemies to spawn in each room")
+	var teams = flag.Bool("teams", false, "split players in every room into the red and blue teams")
+	var friendlyFire = flag.Bool("friendly-fire", false, "let teammates hurt each other")
+	var mode = flag.String("mode", "", "game mode every room plays: deathmatch, teamDeathmatch, kingOfTheHill or captureTheFlag, defaults to no matches")
+	var scoreLimit = flag.Int("score-limit", 0, "score that wins a match, defaults to the game mode's own limit")
+	var timeLimit = flag.Int64("time-limit", 0, "milliseconds before a match ends with the highest score winning, 0 for no limit")
+	var minPlayers = flag.Int("min-players", defaultMinPlayers, "ready players needed in a room to start a match")
+	var seed = flag.Int64("seed", 0, "random seed for every room, so games can be repeated, defaults to a new seed for each room")
+	var recordDir = flag.String("record", "", "directory to record a replay of every room to")
+	var replayPath = flag.String("replay", "", "path to a replay to play back to spectators instead of hosting games")
+	var replaySpeed = flag.Float64("replay-speed", 1, "how fast to play the replay back")
+	var backfill = flag.Int("backfill", 0, "fill rooms with bots up to this many players while anyone is playing")
+	var botDifficulty = flag.String("bot-difficulty", defaultBotDifficulty, "how well backfill bots play: easy, normal or hard")
+	var tuningPath = flag.String("tuning", "", "path to a yaml tuning file for walk and dodge distances, costs, timeouts and sizes, reloaded when it changes or on SIGHUP")
+	var saveDir = flag.String("save-dir", "", "directory to save rooms to when the server shuts down on SIGTERM")
+	var restore = flag.Bool("restore", false, "reopen the rooms saved in -save-dir on startup, so players can reconnect into the same match")
+	var shutdownGrace = flag.Duration("shutdown-grace", 2*time.Second, "how long players see the shutdown message before they are disconnected")
+	var adminToken = flag.String("admin-token", os.Getenv("ADMIN_TOKEN"), "bearer token for the admin api at /admin/, defaults to $ADMIN_TOKEN, the api is off without one")
+	var scenarios = flag.String("scenarios", "", "glob of scenario files to run headlessly instead of serving, exiting non-zero if any fail")
+	var healthRegen = flag.Int("health-regen", defaultRegenRules.Health, "health every actor recovers per refresh")
+	var healthRegenDelay = flag.Int64("health-regen-delay", defaultRegenRules.HealthDelay, "milliseconds after taking damage before health regen starts")
+	flag.Parse()
+
+	// effects are loaded first so weapons and items can be validated against them
+	if *effectsPath != "" {
+		loaded, err := loadEffectKinds(*effectsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		effectKinds = loaded
+	}
+
+	// projectiles are loaded before weapons so weapons can be validated against them
+	if *projectilesPath != "" {
+		loaded, err := loadProjectileKinds(*projectilesPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		projectileKinds = loaded
+	}
+
+	// weapons are validated again even without a file, since they can depend on custom projectiles and effects
+	loadedWeapons, err := parseWeapons(defaultWeaponsJSON)
+	if *weaponsPath != "" {
+		loadedWeapons, err = loadWeapons(*weaponsPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	weapons = loadedWeapons
+
+	// classes are validated again too, since they depend on weapons and effects
+	loadedClasses, err := parseClasses(defaultClassesJSON)
+	if *classesPath != "" {
+		loadedClasses, err = loadClasses(*classesPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	classes = loadedClasses
+
+	// items are validated again too, since they can depend on custom weapons
+	loadedItems, err := parseItemKinds(defaultItemsJSON)
+	if *itemsPath != "" {
+		loadedItems, err = loadItemKinds(*itemsPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	itemKinds = loadedItems
+
+	// spawners are checked against the items, so they are loaded after them
+	spawners, err := parseItemSpawners(defaultSpawnersJSON)
+	if *spawnersPath != "" {
+		spawners, err = loadItemSpawners(*spawnersPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *behaviorsPath != "" {
+		loaded, err := loadBehaviors(*behaviorsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		behaviors = loaded
+	}
+
+	if *scenarios != "" {
+		passed, err := runScenarios(*scenarios)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !passed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = roomSettings{Backfill: *backfill, BotDifficulty: *botDifficulty}.validateBots()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, ok := gameModes[*mode]; *mode != "" && !ok {
+		log.Fatalf("unknown game mode %q", *mode)
+	}
+
+	rooms := newRoomRegistry(func() *gameState {
+		gs := newGameState()
+		if *seed != 0 {
+			gs.seed(*seed)
+		}
+		gs.regen = regenRules{
+			Health:      *healthRegen,
+			HealthDelay: *healthRegenDelay,
+		}
+		gs.Settings.FriendlyFire = *friendlyFire
+		if *teams {
+			gs.Teams = defaultTeams(gs.Bounds)
+		}
+		gs.Settings.ScoreLimit = *scoreLimit
+		gs.Settings.TimeLimit = *timeLimit
+		gs.Settings.MinPlayers = *minPlayers
+		gs.Settings.Backfill = *backfill
+		gs.Settings.BotDifficulty = *botDifficulty
+		if *mode != "" {
+			// the mode sets up teams itself if it needs them
+			gs.setMode(*mode)
+		}
+		gs.spawnEnemies(*enemies)
+		gs.setSpawners(spawners)
+		return gs
+	})
+
+	rooms.recordDir = *recordDir
+
+	// the tuning file is loaded after weapons so weapon overrides can be checked against them
+	if *tuningPath != "" {
+		config, err := loadTuning(*tuningPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rooms.tuning = &config
+		go rooms.watchTuning(*tuningPath, time.Second)
+	}
+
+	if *restore {
+		if *saveDir == "" {
+			log.Fatal("restore needs a save dir")
+		}
+		restored, err := rooms.restoreRooms(*saveDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("restored rooms:", restored)
+	}
 
 	wss := WebsocketServer{
-		addr: *addr,
-		cors: "*",
-		gameState: &gameState{
-			Players: []player{},
-		},
+		addr:          *addr,
+		cors:          "*",
+		rooms:         rooms,
+		server:        &http.Server{Addr: *addr},
+		saveDir:       *saveDir,
+		shutdownGrace: *shutdownGrace,
 	}
-	err := wss.start()
+
+	if *adminToken != "" {
+		wss.admin = &adminAPI{rooms: rooms, token: *adminToken}
+	}
+
+	if *replayPath != "" {
+		header, events, err := loadReplay(*replayPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *replaySpeed <= 0 {
+			log.Fatal("replay speed must be positive")
+		}
+
+		// the replay is played in a room rebuilt from the one it was recorded in, and isn't recorded again
+		rooms.recordDir = ""
+		rm := rooms.get(header.Room)
+		err = rm.startPlayback(header, events, *replaySpeed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		wss.replay = rm
+		go rm.play()
+	}
+	// the server stops on SIGTERM or interrupt once the rooms are closed and saved
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		<-signals
+		log.Println("shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := wss.shutdown(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+		close(stopped)
+	}()
+
+	err = wss.start()
 	if err != nil {
 		log.Fatal(err)
 	}
+	<-stopped
 }
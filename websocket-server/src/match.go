@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+)
+
+// match phases, in the order a match goes through them
+const (
+	// phaseWarmup is the lobby, waiting for enough players to be ready, nothing is scored
+	phaseWarmup = "warmup"
+	// phaseCountdown counts down to the start, going back to warmup if players leave
+	phaseCountdown = "countdown"
+	// phaseInProgress is the match itself, until someone wins or time runs out
+	phaseInProgress = "inProgress"
+	// phaseResults shows the winner
+	phaseResults = "results"
+	// phaseReset puts every player back how they started, then goes back to warmup
+	phaseReset = "reset"
+)
+
+const countdownDuration = 5000
+const resultsDuration = 10000
+
+// respawnDelay is how long dead players wait before coming back, in milliseconds
+const respawnDelay = 3000
+
+// defaultMinPlayers is how many ready players a match needs to start
+const defaultMinPlayers = 2
+
+// matchState is the current match, sent to clients in snapshots
+type matchState struct {
+	Mode  string `json:"mode"`
+	Phase string `json:"phase"`
+	// PhaseEndsAt is when the phase is over in unix milliseconds, 0 if it waits for something else
+	PhaseEndsAt int64 `json:"phaseEndsAt"`
+	// Scores are kept by player name, or by team name in team modes
+	Scores map[string]int `json:"scores"`
+	// Winner is the winning player or team once the match is over, empty for a draw
+	Winner string `json:"winner"`
+	// Hill is the area to hold in king of the hill
+	Hill *boundingBox `json:"hill,omitempty"`
+	// Host is the player who can start the match, kick players and change the settings
+	Host string `json:"host"`
+	// Ready is who is ready for the match to start, by player name
+	Ready map[string]bool `json:"ready"`
+	// forceStart is set when the host starts the countdown without waiting for everyone
+	forceStart bool
+}
+
+// setMode makes the room play the named game mode, starting from warmup
+func (gs *gameState) setMode(name string) error {
+	newMode, ok := gameModes[name]
+	if !ok {
+		return fmt.Errorf("unknown game mode %q", name)
+	}
+	gs.mode = newMode()
+	host := ""
+	if gs.Match != nil {
+		host = gs.Match.Host
+	}
+	// flags only belong in the mode that spawned them
+	for _, e := range gs.entities {
+		if e.Flag != nil {
+			gs.removeEntity(e.ID)
+		}
+	}
+	gs.Settings.Mode = name
+	gs.Match = &matchState{
+		Mode:   name,
+		Phase:  phaseWarmup,
+		Scores: map[string]int{},
+		Host:   host,
+		Ready:  map[string]bool{},
+	}
+
+	// team modes need teams to play in, the rest play every player for themselves
+	if gs.mode.teams() && len(gs.Teams) == 0 {
+		gs.Teams = defaultTeams(gs.Bounds)
+	}
+	if !gs.mode.teams() {
+		gs.Teams = []team{}
+	}
+	gs.assignTeams()
+	return nil
+}
+
+// scoreLimit is the score that wins the match, from the room's settings or the mode's default
+func (gs *gameState) scoreLimit() int {
+	if gs.Settings.ScoreLimit > 0 {
+		return gs.Settings.ScoreLimit
+	}
+	return gs.mode.defaultScoreLimit()
+}
+
+func (gs *gameState) minPlayers() int {
+	if gs.Settings.MinPlayers > 0 {
+		return gs.Settings.MinPlayers
+	}
+	return defaultMinPlayers
+}
+
+func (gs *gameState) playerCount() int {
+	count := 0
+	for _, e := range gs.entities {
+		if e.Kind == "player" {
+			count++
+		}
+	}
+	return count
+}
+
+// setPhase moves the match on to the phase, ending after duration milliseconds if it isn't 0
+func (gs *gameState) setPhase(phase string, duration int64) {
+	gs.Match.Phase = phase
+	gs.Match.PhaseEndsAt = 0
+	if duration > 0 {
+		gs.Match.PhaseEndsAt = gs.now() + duration
+	}
+}
+
+// matchSystem moves the match through its lifecycle
+func matchSystem(gs *gameState) {
+	if gs.mode == nil {
+		return
+	}
+
+	now := gs.now()
+	phaseOver := gs.Match.PhaseEndsAt > 0 && now >= gs.Match.PhaseEndsAt
+	switch gs.Match.Phase {
+	case phaseWarmup:
+		if gs.canStart() {
+			gs.setPhase(phaseCountdown, countdownDuration)
+		}
+	case phaseCountdown:
+		if !gs.canStart() {
+			gs.setPhase(phaseWarmup, 0)
+			return
+		}
+		if phaseOver {
+			gs.startMatch()
+		}
+	case phaseInProgress:
+		if gs.playerCount() == 0 {
+			gs.setPhase(phaseReset, 0)
+			return
+		}
+		gs.mode.update(gs)
+		if winner, ok := gs.mode.winner(gs); ok {
+			gs.endMatch(winner)
+			return
+		}
+		if phaseOver {
+			gs.endMatch(gs.leader())
+		}
+	case phaseResults:
+		if phaseOver {
+			gs.setPhase(phaseReset, 0)
+		}
+	case phaseReset:
+		gs.resetMatch()
+		gs.setPhase(phaseWarmup, 0)
+	}
+}
+
+// startMatch resets everyone and starts scoring
+func (gs *gameState) startMatch() {
+	gs.resetMatch()
+	gs.mode.start(gs)
+	gs.setPhase(phaseInProgress, gs.Settings.TimeLimit)
+}
+
+func (gs *gameState) endMatch(winner string) {
+	gs.Match.Winner = winner
+	gs.setPhase(phaseResults, resultsDuration)
+}
+
+// resetMatch clears the scores and puts every player back at full health in their spawn
+// everyone has to get ready again for the next match
+func (gs *gameState) resetMatch() {
+	gs.Match.Scores = map[string]int{}
+	gs.Match.Winner = ""
+	gs.Match.Ready = map[string]bool{}
+	gs.Match.forceStart = false
+	for _, e := range gs.entities {
+		if e.Kind == "player" {
+			e.Actor.Kills = 0
+			e.Actor.Deaths = 0
+			gs.respawn(e)
+		}
+	}
+	// nothing fired before the match carries over into it
+	for _, e := range gs.entities {
+		if e.Projectile != nil {
+			gs.removeEntity(e.ID)
+		}
+		if e.Flag != nil {
+			e.returnHome()
+		}
+	}
+}
+
+// leader returns the player or team with the highest score, empty if it's a draw
+func (gs *gameState) leader() string {
+	leader := ""
+	best := 0
+	draw := false
+	for _, name := range sortedKeys(gs.Match.Scores) {
+		score := gs.Match.Scores[name]
+		switch {
+		case leader == "" || score > best:
+			leader = name
+			best = score
+			draw = false
+		case score == best:
+			draw = true
+		}
+	}
+	if draw {
+		return ""
+	}
+	return leader
+}
+
+// scoreLimitWinner is the win condition most modes use, first to the score limit
+func (gs *gameState) scoreLimitWinner() (string, bool) {
+	for _, name := range sortedKeys(gs.Match.Scores) {
+		if gs.Match.Scores[name] >= gs.scoreLimit() {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// killed records the victim's death and gives the kill to whoever hit them last
+func (gs *gameState) killed(victim *entity) {
+	if victim.Actor == nil {
+		return
+	}
+
+	var killer *entity
+	k, err := gs.getEntity(victim.Health.lastHitBy)
+	if err == nil && k.ID != victim.ID && k.Actor != nil {
+		killer = k
+		killer.Actor.Kills++
+	}
+	victim.Actor.Deaths++
+
+	if victim.Kind == "player" {
+		victim.Actor.respawnAt = gs.now() + respawnDelay
+		victim.Actor.IsWalking = false
+		victim.Actor.IsAttacking = false
+		victim.Actor.path = nil
+	}
+
+	if gs.mode != nil && gs.Match.Phase == phaseInProgress {
+		gs.mode.killed(gs, killer, victim)
+	}
+}
+
+// respawnSystem brings dead players back once they have waited long enough
+func respawnSystem(gs *gameState) {
+	now := gs.now()
+	for _, e := range gs.entities {
+		if e.Kind != "player" || e.Health.Current > 0 || e.Actor.respawnAt == 0 {
+			continue
+		}
+		if now >= e.Actor.respawnAt {
+			gs.respawn(e)
+		}
+	}
+}
+
+// respawn puts the player back in their spawn as good as new
+func (gs *gameState) respawn(e *entity) {
+	e.Health.Current = e.Health.Max
+	e.Health.lastHitBy = 0
+	for _, pool := range e.Actor.Resources {
+		pool.Current = pool.Max
+	}
+	e.Actor.Effects = nil
+	e.Actor.IsAttacking = false
+	e.Actor.IsWalking = false
+	e.Actor.IsDodging = false
+	e.Actor.IsUsing = false
+	e.Actor.AbilityReadyAt = 0
+	e.Actor.respawnAt = 0
+	e.Actor.path = nil
+
+	if t, ok := gs.getTeam(e.Actor.Team); ok {
+		gs.spawnInArea(e, t.Spawn)
+		return
+	}
+	e.Position.X = e.Actor.spawn.X
+	e.Position.Y = e.Actor.spawn.Y
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMatch returns a room playing the mode with the players in it
+func newTestMatch(t *testing.T, mode string, players ...player) *gameState {
+	gs := newGameState()
+	require.NoError(t, gs.setMode(mode))
+	for _, p := range players {
+		gs.addPlayer(p)
+	}
+	return gs
+}
+
+// endTestPhase makes the current phase run out
+func endTestPhase(gs *gameState) {
+	gs.Match.PhaseEndsAt = gs.now() - 1
+}
+
+// startTestMatch gets everyone ready and skips the countdown
+func startTestMatch(gs *gameState) {
+	for _, e := range gs.entities {
+		if e.Kind == "player" {
+			gs.playerReady(e.Actor.Name, true)
+		}
+	}
+	gs.refresh()
+	endTestPhase(gs)
+	gs.refresh()
+}
+
+func TestSetMode(t *testing.T) {
+	gs := newGameState()
+	require.EqualError(t, gs.setMode("tag"), `unknown game mode "tag"`)
+
+	// team modes bring their own teams, free for all takes them away
+	require.NoError(t, gs.setMode("teamDeathmatch"))
+	require.Len(t, gs.Teams, 2)
+	require.Equal(t, phaseWarmup, gs.Match.Phase)
+	require.NoError(t, gs.setMode("deathmatch"))
+	require.Empty(t, gs.Teams)
+	require.Equal(t, "deathmatch", gs.Settings.Mode)
+}
+
+func TestMatchLifecycle(t *testing.T) {
+	gs := newTestMatch(t, "deathmatch", player{Name: "player1", Facing: "down"})
+
+	// one ready player isn't enough to start
+	gs.playerReady("player1", true)
+	gs.refresh()
+	require.Equal(t, phaseWarmup, gs.Match.Phase)
+
+	gs.addPlayer(player{X: 100, Name: "player2", Facing: "down"})
+	gs.refresh()
+	require.Equal(t, phaseWarmup, gs.Match.Phase)
+	gs.playerReady("player2", true)
+	gs.refresh()
+	require.Equal(t, phaseCountdown, gs.Match.Phase)
+
+	// the countdown stops if a ready player leaves
+	gs.removePlayer("player2")
+	gs.refresh()
+	require.Equal(t, phaseWarmup, gs.Match.Phase)
+
+	gs.addPlayer(player{X: 100, Name: "player2", Facing: "down"})
+	startTestMatch(gs)
+	require.Equal(t, phaseInProgress, gs.Match.Phase)
+	require.Equal(t, map[string]int{"player1": 0, "player2": 0}, gs.Match.Scores)
+
+	// first to the score limit wins
+	gs.Settings.ScoreLimit = 1
+	gs.Match.Scores["player2"] = 1
+	gs.refresh()
+	require.Equal(t, phaseResults, gs.Match.Phase)
+	require.Equal(t, "player2", gs.Match.Winner)
+
+	endTestPhase(gs)
+	gs.refresh()
+	require.Equal(t, phaseReset, gs.Match.Phase)
+	gs.refresh()
+	require.Equal(t, phaseWarmup, gs.Match.Phase)
+	require.Empty(t, gs.Match.Scores)
+	require.Empty(t, gs.Match.Winner)
+	require.Empty(t, gs.Match.Ready)
+}
+
+func TestMatchTimeLimit(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		scores map[string]int
+		winner string
+	}{
+		{name: "leader", scores: map[string]int{"player1": 2, "player2": 1}, winner: "player1"},
+		{name: "draw", scores: map[string]int{"player1": 1, "player2": 1}, winner: ""},
+	} {
+		gs := newTestMatch(t, "deathmatch",
+			player{Name: "player1", Facing: "down"},
+			player{X: 100, Name: "player2", Facing: "down"},
+		)
+		gs.Settings.TimeLimit = 60000
+		startTestMatch(gs)
+		require.NotZero(t, gs.Match.PhaseEndsAt, tc.name)
+
+		gs.Match.Scores = tc.scores
+		endTestPhase(gs)
+		gs.refresh()
+		require.Equal(t, phaseResults, gs.Match.Phase, tc.name)
+		require.Equal(t, tc.winner, gs.Match.Winner, tc.name)
+	}
+}
+
+func TestKillsAreScored(t *testing.T) {
+	gs := newTestMatch(t, "deathmatch",
+		testPlayer1FacingRight,
+		player{X: playerSpriteWidth + 5, Y: 0, Name: "player2", Facing: "left", Skin: "skin2"},
+	)
+	startTestMatch(gs)
+
+	p1, err := gs.getPlayer("player1")
+	require.NoError(t, err)
+	p2, err := gs.getPlayer("player2")
+	require.NoError(t, err)
+	gs.hit(p1, p2, p2.Health.Current)
+
+	require.Equal(t, 1, gs.Match.Scores["player1"])
+	require.Equal(t, 1, getTestPlayer(t, gs, "player1").Kills)
+	require.Equal(t, 1, getTestPlayer(t, gs, "player2").Deaths)
+
+	// the dead can't be hit again or do anything
+	gs.hit(p1, p2, 10)
+	require.Equal(t, 1, gs.Match.Scores["player1"])
+	require.Empty(t, gs.attackTargets(p1))
+	gs.playerWalk("player2", "left")
+	require.Equal(t, playerSpriteWidth+5, p2.Position.X)
+}
+
+func TestTeamKillsAreScored(t *testing.T) {
+	gs := newTestMatch(t, "teamDeathmatch",
+		player{Name: "player1", Team: "red", Facing: "down"},
+		player{Name: "player2", Team: "red", Facing: "down"},
+		player{Name: "player3", Team: "blue", Facing: "down"},
+	)
+	gs.Settings.FriendlyFire = true
+	startTestMatch(gs)
+
+	p1, _ := gs.getPlayer("player1")
+	p2, _ := gs.getPlayer("player2")
+	p3, _ := gs.getPlayer("player3")
+	gs.hit(p1, p3, p3.Health.Current)
+	gs.hit(p1, p2, p2.Health.Current)
+	require.Equal(t, map[string]int{"red": 1, "blue": 0}, gs.Match.Scores)
+}
+
+func TestRespawn(t *testing.T) {
+	gs := newTestGameState(player{X: 100, Y: 100, Name: "player1", Facing: "down"})
+	p, err := gs.getPlayer("player1")
+	require.NoError(t, err)
+
+	p.Position.X = 300
+	gs.applyEffect(p, "poison")
+	gs.damage(p, p.Health.Max)
+	require.True(t, p.isDead())
+	require.Equal(t, 1, p.Actor.Deaths)
+
+	gs.refresh()
+	require.True(t, p.isDead())
+
+	// players come back where they joined once the delay is up
+	p.Actor.respawnAt = gs.now() - 1
+	gs.refresh()
+	require.Equal(t, p.Health.Max, p.Health.Current)
+	require.Empty(t, p.Actor.Effects)
+	require.Equal(t, 100, p.Position.X)
+}
+
+func TestKingOfTheHill(t *testing.T) {
+	gs := newTestMatch(t, "kingOfTheHill",
+		player{Name: "player1", Team: "red", Facing: "down"},
+		player{Name: "player2", Team: "blue", Facing: "down"},
+	)
+	startTestMatch(gs)
+	require.NotNil(t, gs.Match.Hill)
+	hill := *gs.Match.Hill
+	mode := gs.mode.(*kingOfTheHillMode)
+
+	p1, _ := gs.getPlayer("player1")
+	p1.Position.X = hill.X
+	p1.Position.Y = hill.Y
+	mode.nextPointAt = 0
+	gs.refresh()
+	require.Equal(t, 1, gs.Match.Scores["red"])
+
+	// nobody scores while the hill is contested
+	p2, _ := gs.getPlayer("player2")
+	p2.Position.X = hill.X + hill.Width - playerSpriteWidth
+	p2.Position.Y = hill.Y + hill.Height - playerSpriteHeight
+	mode.nextPointAt = 0
+	gs.refresh()
+	require.Equal(t, map[string]int{"red": 1, "blue": 0}, gs.Match.Scores)
+}
+
+func TestCaptureTheFlag(t *testing.T) {
+	gs := newTestMatch(t, "captureTheFlag",
+		player{Name: "player1", Team: "red", Facing: "down"},
+		player{Name: "player2", Team: "blue", Facing: "down"},
+	)
+	startTestMatch(gs)
+
+	flags := map[string]*entity{}
+	for _, e := range gs.entities {
+		if e.Flag != nil {
+			flags[e.Flag.Team] = e
+		}
+	}
+	require.Len(t, flags, 2)
+	p1, _ := gs.getPlayer("player1")
+	p2, _ := gs.getPlayer("player2")
+
+	// moves the player so their hitbox is on the flag
+	moveOnto := func(p, flag *entity) {
+		p.Position.X = flag.Position.X - p.Hitbox.OffsetX
+		p.Position.Y = flag.Position.Y - p.Hitbox.OffsetY
+	}
+
+	// an enemy picks the flag up, and drops it where they die
+	moveOnto(p1, flags["blue"])
+	gs.refresh()
+	require.Equal(t, p1.ID, flags["blue"].Flag.Carrier)
+	p1.Position.X -= 100
+	gs.refresh()
+	require.Equal(t, p1.Position.X, flags["blue"].Position.X)
+	gs.damage(p1, p1.Health.Max)
+	gs.refresh()
+	require.Zero(t, flags["blue"].Flag.Carrier)
+	require.False(t, flags["blue"].isHome())
+
+	// a teammate returns it
+	moveOnto(p2, flags["blue"])
+	gs.refresh()
+	require.True(t, flags["blue"].isHome())
+
+	// bringing the enemy flag to your own scores
+	gs.respawn(p1)
+	p2.Position.X = 400
+	moveOnto(p1, flags["blue"])
+	gs.refresh()
+	require.Equal(t, p1.ID, flags["blue"].Flag.Carrier)
+	moveOnto(p1, flags["red"])
+	gs.refresh()
+	require.Equal(t, 1, gs.Match.Scores["red"])
+	require.True(t, flags["blue"].isHome())
+}
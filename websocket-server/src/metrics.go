@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// metrics are served at /metrics for prometheus to scrape
+
+var connectedClients = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "toast_connected_clients",
+	Help: "Websocket connections, by whether they are playing or spectating.",
+}, []string{"role"})
+
+var roomPlayers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "toast_room_players",
+	Help: "Players in each open room, bots included.",
+}, []string{"room"})
+
+var tickDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "toast_tick_duration_seconds",
+	Help:    "How long refreshes take to run every system.",
+	Buckets: prometheus.ExponentialBuckets(0.00005, 2, 14),
+})
+
+var marshalDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "toast_snapshot_marshal_duration_seconds",
+	Help:    "How long snapshots take to encode as json.",
+	Buckets: prometheus.ExponentialBuckets(0.00005, 2, 14),
+})
+
+var messagesIn = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "toast_messages_received_total",
+	Help: "Events received from clients, by event type.",
+}, []string{"type"})
+
+var messagesOut = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "toast_messages_sent_total",
+	Help: "Messages sent to clients, snapshots in reply to events or broadcast to spectators.",
+}, []string{"type"})
+
+var bytesSent = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "toast_bytes_sent_total",
+	Help: "Bytes of messages sent to clients.",
+})
+
+var droppedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "toast_dropped_events_total",
+	Help: "Events and snapshots that were thrown away, by why.",
+}, []string{"reason"})
+
+// eventTypes are the events clients can send, anything else is counted as unknown
+// so clients can't fill the metrics with made up types
+var eventTypes = map[string]bool{
+	"refresh":  true,
+	"attack":   true,
+	"walk":     true,
+	"moveTo":   true,
+	"dodge":    true,
+	"ability":  true,
+	"use":      true,
+	"drop":     true,
+	"chat":     true,
+	"mute":     true,
+	"unmute":   true,
+	"ready":    true,
+	"choose":   true,
+	"start":    true,
+	"kick":     true,
+	"settings": true,
+	"join":     true,
+	"leave":    true,
+	"spectate": true,
+	"follow":   true,
+	"camera":   true,
+	"speed":    true,
+}
+
+// countEvent records an event received from a client
+func countEvent(eventType string) {
+	if !eventTypes[eventType] {
+		messagesIn.WithLabelValues("unknown").Inc()
+		droppedEvents.WithLabelValues("unknown type").Inc()
+		return
+	}
+	messagesIn.WithLabelValues(eventType).Inc()
+}
+
+// countSent records a message sent to a client
+func countSent(messageType string, message []byte) {
+	messagesOut.WithLabelValues(messageType).Inc()
+	bytesSent.Add(float64(len(message)))
+}
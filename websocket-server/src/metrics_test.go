@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	wss := WebsocketServer{cors: "*", rooms: newRoomRegistry(newGameState)}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	joins := testutil.ToFloat64(messagesIn.WithLabelValues("join"))
+	unknown := testutil.ToFloat64(droppedEvents.WithLabelValues("unknown type"))
+	invalid := testutil.ToFloat64(droppedEvents.WithLabelValues("invalid json"))
+	sent := testutil.ToFloat64(bytesSent)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?room=metrics"
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, c.WriteJSON(gameEvent{Type: "join", Data: eventData{player: testPlayer1FacingRight}}))
+	_, snapshot, err := c.ReadMessage()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, testutil.ToFloat64(connectedClients.WithLabelValues("player")), float64(1))
+	require.Equal(t, float64(1), testutil.ToFloat64(roomPlayers.WithLabelValues("metrics")))
+	rooms := testutil.CollectAndCount(roomPlayers)
+
+	require.NoError(t, c.WriteJSON(gameEvent{Type: "dance"}))
+	_, _, err = c.ReadMessage()
+	require.NoError(t, err)
+
+	require.Equal(t, joins+1, testutil.ToFloat64(messagesIn.WithLabelValues("join")))
+	require.Equal(t, unknown+1, testutil.ToFloat64(droppedEvents.WithLabelValues("unknown type")))
+	require.GreaterOrEqual(t, testutil.ToFloat64(bytesSent), sent+float64(len(snapshot)))
+
+	// the server hangs up on messages that aren't events
+	require.NoError(t, c.WriteMessage(websocket.TextMessage, []byte("{")))
+	_, _, err = c.ReadMessage()
+	require.Error(t, err)
+	require.Equal(t, invalid+1, testutil.ToFloat64(droppedEvents.WithLabelValues("invalid json")))
+	c.Close()
+
+	// the room closes now nobody is in it, taking its series with it
+	require.Eventually(t, func() bool { return testutil.CollectAndCount(roomPlayers) == rooms-1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRoomCountIsCapped(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	for i := 0; i < maxRooms; i++ {
+		rooms.get(fmt.Sprintf("room%d", i))
+	}
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	// rooms already open can still be joined
+	c := dialTestRoom(t, server, "room0")
+	sendTestEvent(t, c, gameEvent{Type: "refresh"})
+
+	// but no more can be opened
+	c = dialTestRoom(t, server, "another")
+	_, _, err := c.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater))
+	require.Len(t, rooms.list(), maxRooms)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	newTestGameState(testPlayer1FacingRight).refresh()
+
+	recorder := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "toast_tick_duration_seconds_count")
+	require.Contains(t, string(body), "toast_snapshot_marshal_duration_seconds")
+}
@@ -0,0 +1,140 @@
+package main
+
+// hillSize is the width and height of the king of the hill area
+const hillSize = 192
+
+// hillPointInterval is how long the hill has to be held for each point, in milliseconds
+const hillPointInterval = 1000
+
+// gameMode decides how a match is scored and won
+type gameMode interface {
+	// teams reports whether the mode is played in teams, scores are then kept by team
+	teams() bool
+	// defaultScoreLimit wins the match when the room doesn't set one
+	defaultScoreLimit() int
+	// start sets up anything the mode needs when the match starts
+	start(gs *gameState)
+	// update runs on every refresh while the match is in progress
+	update(gs *gameState)
+	// killed is called for every death while the match is in progress, killer is nil if nobody gets the kill
+	killed(gs *gameState, killer, victim *entity)
+	// winner returns who has won, if anyone has yet
+	winner(gs *gameState) (string, bool)
+}
+
+// gameModes are the modes a room can play, by name
+var gameModes = map[string]func() gameMode{
+	"deathmatch":     func() gameMode { return &deathmatchMode{} },
+	"teamDeathmatch": func() gameMode { return &teamDeathmatchMode{} },
+	"kingOfTheHill":  func() gameMode { return &kingOfTheHillMode{} },
+	"captureTheFlag": func() gameMode { return &captureTheFlagMode{} },
+}
+
+// startTeamScores starts every team on 0, so a team nobody scores for can still draw
+func startTeamScores(gs *gameState) {
+	for _, t := range gs.Teams {
+		gs.Match.Scores[t.Name] = 0
+	}
+}
+
+// deathmatchMode is every player for themselves, scoring a point for each kill
+type deathmatchMode struct{}
+
+func (m *deathmatchMode) teams() bool            { return false }
+func (m *deathmatchMode) defaultScoreLimit() int { return 20 }
+
+func (m *deathmatchMode) start(gs *gameState) {
+	for _, e := range gs.entities {
+		if e.Kind == "player" {
+			gs.Match.Scores[e.Actor.Name] = 0
+		}
+	}
+}
+
+func (m *deathmatchMode) update(gs *gameState) {}
+
+func (m *deathmatchMode) killed(gs *gameState, killer, victim *entity) {
+	if killer != nil && killer.Kind == "player" {
+		gs.Match.Scores[killer.Actor.Name]++
+	}
+}
+
+func (m *deathmatchMode) winner(gs *gameState) (string, bool) {
+	return gs.scoreLimitWinner()
+}
+
+// teamDeathmatchMode scores a point for the team for each enemy killed
+type teamDeathmatchMode struct{}
+
+func (m *teamDeathmatchMode) teams() bool            { return true }
+func (m *teamDeathmatchMode) defaultScoreLimit() int { return 50 }
+func (m *teamDeathmatchMode) start(gs *gameState)    { startTeamScores(gs) }
+func (m *teamDeathmatchMode) update(gs *gameState)   {}
+
+func (m *teamDeathmatchMode) killed(gs *gameState, killer, victim *entity) {
+	if killer == nil || killer.Actor.Team == "" || teammates(killer, victim) {
+		return
+	}
+	gs.Match.Scores[killer.Actor.Team]++
+}
+
+func (m *teamDeathmatchMode) winner(gs *gameState) (string, bool) {
+	return gs.scoreLimitWinner()
+}
+
+// kingOfTheHillMode scores a point for the team every second it is the only one on the hill
+type kingOfTheHillMode struct {
+	nextPointAt int64
+}
+
+func (m *kingOfTheHillMode) teams() bool            { return true }
+func (m *kingOfTheHillMode) defaultScoreLimit() int { return 60 }
+
+// start puts the hill in the middle of the map
+func (m *kingOfTheHillMode) start(gs *gameState) {
+	startTeamScores(gs)
+	gs.Match.Hill = &boundingBox{
+		X:      gs.Bounds.X + (gs.Bounds.Width-hillSize)/2,
+		Y:      gs.Bounds.Y + (gs.Bounds.Height-hillSize)/2,
+		Width:  hillSize,
+		Height: hillSize,
+	}
+	m.nextPointAt = gs.now() + hillPointInterval
+}
+
+func (m *kingOfTheHillMode) update(gs *gameState) {
+	now := gs.now()
+	if now < m.nextPointAt {
+		return
+	}
+	m.nextPointAt = now + hillPointInterval
+
+	holder, ok := hillHolder(gs)
+	if ok {
+		gs.Match.Scores[holder]++
+	}
+}
+
+// hillHolder returns the team holding the hill, if only one team is alive on it
+func hillHolder(gs *gameState) (string, bool) {
+	holder := ""
+	for _, e := range gs.entities {
+		if e.Kind != "player" || e.isDead() || e.Actor.Team == "" {
+			continue
+		}
+		if !boxesOverlap(e.hitboxBox(), *gs.Match.Hill) {
+			continue
+		}
+		if holder != "" && holder != e.Actor.Team {
+			return "", false
+		}
+		holder = e.Actor.Team
+	}
+	return holder, holder != ""
+}
+
+func (m *kingOfTheHillMode) killed(gs *gameState, killer, victim *entity) {}
+
+func (m *kingOfTheHillMode) winner(gs *gameState) (string, bool) {
+	return gs.scoreLimitWinner()
+}
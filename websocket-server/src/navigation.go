@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+
+	"toast-websocket-server/src/pathfinding"
+)
+
+// navigationCellSize is the resolution of the navigation grid, smaller than the player hitbox
+const navigationCellSize = 8
+
+// defaultBounds is the area of the map the navigation grid covers
+var defaultBounds = boundingBox{X: 0, Y: 0, Width: 1920, Height: 1080}
+
+// navigation returns the navigation grid for the map, building it on first use
+// so the walls have to be in place before anything looks for a path
+func (gs *gameState) navigation() *pathfinding.Grid {
+	if gs.navGrid == nil {
+		walls := []pathfinding.Rect{}
+		for _, wall := range gs.Walls {
+			walls = append(walls, pathfinding.Rect(wall))
+		}
+		gs.navGrid = pathfinding.NewGrid(pathfinding.Rect(gs.Bounds), navigationCellSize, walls, gs.tuning.Hitbox.Width, gs.tuning.Hitbox.Height)
+	}
+	return gs.navGrid
+}
+
+// findPath finds a path that takes the entity's hitbox around walls to where it would be at x, y
+func (gs *gameState) findPath(e *entity, x, y int) ([]pathfinding.Point, bool) {
+	from := e.hitboxBox()
+	to := e.hitboxAt(x, y)
+	return gs.navigation().FindPath(pathfinding.Point{X: from.X, Y: from.Y}, pathfinding.Point{X: to.X, Y: to.Y})
+}
+
+// followPath walks the actor one step toward the next waypoint on its path,
+// dropping waypoints once they have been reached. Being within half a step
+// either way counts as being there, so actors don't step back and forth over it.
+func (gs *gameState) followPath(e *entity) {
+	hitbox := e.hitboxBox()
+	reached := max(gs.walkDistance(e)/2, 1)
+	for len(e.Actor.path) > 0 {
+		next := e.Actor.path[0]
+		if abs(next.X-hitbox.X) > reached || abs(next.Y-hitbox.Y) > reached {
+			gs.walk(e, directionToward(hitbox.X, hitbox.Y, next.X, next.Y))
+			return
+		}
+		e.Actor.path = e.Actor.path[1:]
+	}
+}
+
+// pathSystem moves every actor that has somewhere to go
+func pathSystem(gs *gameState) {
+	for _, e := range gs.entities {
+		if e.Actor != nil && e.AI == nil && len(e.Actor.path) > 0 {
+			gs.followPath(e)
+		}
+	}
+}
+
+// playerMoveTo sets the player walking around walls to x, y over the next refreshes
+func (gs *gameState) playerMoveTo(name string, x, y int) {
+	p, err := gs.getPlayer(name)
+	if err != nil {
+		log.Println("cannot find moving player")
+		return
+	}
+
+	path, ok := gs.findPath(p, x, y)
+	if !ok {
+		return
+	}
+	p.Actor.path = path
+}
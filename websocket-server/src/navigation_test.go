@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayerMoveToWalksAroundWalls(t *testing.T) {
+	gs := newTestGameState(player{X: 100, Y: 100, Name: "player1", Health: 100, Facing: "right", Skin: "skin1"})
+	gs.Walls = []boundingBox{{X: 200, Y: 0, Width: 20, Height: 300}}
+
+	gs.playerMoveTo("player1", 300, 100)
+	p, _ := gs.getPlayer("player1")
+	require.NotEmpty(t, p.Actor.path)
+
+	for i := 0; i < 1000 && len(p.Actor.path) > 0; i++ {
+		gs.refresh()
+		require.False(t, gs.collidesWithWall(p.hitboxBox()))
+	}
+	require.Empty(t, p.Actor.path)
+	require.InDelta(t, 300, p.Position.X, 2)
+	require.InDelta(t, 100, p.Position.Y, 2)
+}
+
+func TestWalkingCancelsMoveTo(t *testing.T) {
+	gs := newTestGameState(player{X: 100, Y: 100, Name: "player1", Health: 100, Facing: "right", Skin: "skin1"})
+
+	gs.playerMoveTo("player1", 300, 100)
+	gs.playerWalk("player1", "up")
+	p, _ := gs.getPlayer("player1")
+	require.Empty(t, p.Actor.path)
+	require.Equal(t, 98, p.Position.Y)
+}
+
+func TestPlayerMoveToUnreachable(t *testing.T) {
+	gs := newTestGameState(player{X: 100, Y: 100, Name: "player1", Health: 100, Facing: "right", Skin: "skin1"})
+
+	gs.playerMoveTo("player1", -500, 100)
+	p, _ := gs.getPlayer("player1")
+	require.Empty(t, p.Actor.path)
+}
+
+func TestNPCChasesAroundWalls(t *testing.T) {
+	gs := newTestGameState(player{X: 400, Y: 300, Name: "player1", Health: 100, Facing: "left", Skin: "skin1"})
+	// a wall between the npc and the player, open below
+	gs.Walls = []boundingBox{{X: 360, Y: 200, Width: 10, Height: 140}}
+	npc := gs.addNPC("enemy1", "grunt", 300, 300)
+
+	// heading straight right would walk into the wall, so the npc has to go around it
+	aiSystem(gs)
+	require.Equal(t, "chase", npc.AI.Action)
+
+	lowest := npc.Position.Y
+	for i := 0; i < 200 && npc.AI.Action == "chase"; i++ {
+		aiSystem(gs)
+		require.False(t, gs.collidesWithWall(npc.hitboxBox()))
+		lowest = max(lowest, npc.Position.Y)
+	}
+	require.Equal(t, "attack", npc.AI.Action)
+	require.Greater(t, lowest, 300)
+}
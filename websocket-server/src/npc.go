@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+
+	"toast-websocket-server/src/pathfinding"
+)
+
+// npcPatrolDistance is the size of the square npcs patrol around where they spawned
+const npcPatrolDistance = 64
+
+// aiComponent lets the server control an actor using a behavior tree
+type aiComponent struct {
+	Behavior string `json:"behavior"`
+	// Action is the action the behavior tree chose on the last refresh
+	Action      string `json:"action"`
+	target      entityID
+	patrol      []positionComponent
+	patrolIndex int
+}
+
+// addNPC spawns a server controlled enemy at x, y using the named behavior tree
+func (gs *gameState) addNPC(name, behavior string, x, y int) *entity {
+	e := newActorEntity("npc", player{
+		X:       x,
+		Y:       y,
+		Name:    name,
+		Health:  100,
+		Stamina: 100,
+		Facing:  "down",
+		Skin:    "skin2",
+	}, gs.tuning)
+	e.AI = &aiComponent{
+		Behavior: behavior,
+		patrol: []positionComponent{
+			{X: x, Y: y},
+			{X: x + npcPatrolDistance, Y: y},
+			{X: x + npcPatrolDistance, Y: y + npcPatrolDistance},
+			{X: x, Y: y + npcPatrolDistance},
+		},
+	}
+	return gs.addEntity(e)
+}
+
+// spawnEnemies adds count npcs in a row using the default behavior
+func (gs *gameState) spawnEnemies(count int) {
+	gs.enemies += count
+	for i := 0; i < count; i++ {
+		gs.addNPC(fmt.Sprintf("enemy%d", i+1), "grunt", 100+i*100, 300)
+	}
+}
+
+// aiSystem lets every living npc decide what to do
+func aiSystem(gs *gameState) {
+	for _, e := range gs.entities {
+		if e.AI == nil || e.Health.Current <= 0 {
+			continue
+		}
+		tree, ok := behaviors[e.AI.Behavior]
+		if !ok {
+			continue
+		}
+		e.AI.Action = ""
+		tree.run(gs, e)
+	}
+}
+
+// deathSystem removes everything but players once their health runs out
+func deathSystem(gs *gameState) {
+	for _, e := range gs.entities {
+		if e.Kind != "player" && e.Health != nil && e.Health.Current <= 0 {
+			gs.removeEntity(e.ID)
+		}
+	}
+}
+
+func entityCenter(e *entity) (int, int) {
+	sprite := e.spriteBox()
+	return sprite.X + sprite.Width/2, sprite.Y + sprite.Height/2
+}
+
+// directionToward returns the facing direction that gets closest to x, y from fromX, fromY
+func directionToward(fromX, fromY, x, y int) string {
+	dx := x - fromX
+	dy := y - fromY
+	if abs(dx) > abs(dy) {
+		if dx > 0 {
+			return "right"
+		}
+		return "left"
+	}
+	if dy > 0 {
+		return "down"
+	}
+	return "up"
+}
+
+func oppositeDirection(direction string) string {
+	switch direction {
+	case "up":
+		return "down"
+	case "down":
+		return "up"
+	case "left":
+		return "right"
+	}
+	return "left"
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// getTarget returns the npc's current target if it is still alive
+func (gs *gameState) getTarget(e *entity) (*entity, bool) {
+	target, err := gs.getEntity(e.AI.target)
+	if err != nil || target.Health == nil || target.Health.Current <= 0 {
+		return nil, false
+	}
+	return target, true
+}
+
+func healthBelowCondition(gs *gameState, e *entity, percent int) bool {
+	return e.Health.Current*100 < percent*e.Health.Max
+}
+
+// targetWithinCondition targets the nearest living player within distance
+func targetWithinCondition(gs *gameState, e *entity, distance int) bool {
+	x, y := entityCenter(e)
+	var nearest *entity
+	for _, other := range gs.entities {
+		if other.Kind != "player" || other.Health.Current <= 0 {
+			continue
+		}
+		otherX, otherY := entityCenter(other)
+		if !withinRadius(x, y, otherX, otherY, distance) {
+			continue
+		}
+		if nearest == nil {
+			nearest = other
+			continue
+		}
+		nearestX, nearestY := entityCenter(nearest)
+		if (otherX-x)*(otherX-x)+(otherY-y)*(otherY-y) < (nearestX-x)*(nearestX-x)+(nearestY-y)*(nearestY-y) {
+			nearest = other
+		}
+	}
+
+	if nearest == nil {
+		return false
+	}
+	e.AI.target = nearest.ID
+	return true
+}
+
+func hasStaminaCondition(gs *gameState, e *entity, stamina int) bool {
+	return gs.canAfford(e, resourceCost{"stamina": stamina})
+}
+
+func idleAction(gs *gameState, e *entity) bool {
+	return true
+}
+
+// patrolAction walks between the npc's patrol points in order
+func patrolAction(gs *gameState, e *entity) bool {
+	if len(e.AI.patrol) == 0 {
+		return false
+	}
+
+	point := e.AI.patrol[e.AI.patrolIndex]
+	if abs(point.X-e.Position.X) <= 2 && abs(point.Y-e.Position.Y) <= 2 {
+		e.AI.patrolIndex = (e.AI.patrolIndex + 1) % len(e.AI.patrol)
+		point = e.AI.patrol[e.AI.patrolIndex]
+	}
+
+	gs.walk(e, directionToward(e.Position.X, e.Position.Y, point.X, point.Y))
+	return true
+}
+
+// chaseAction walks toward the target, around any walls in the way
+func chaseAction(gs *gameState, e *entity) bool {
+	target, ok := gs.getTarget(e)
+	if !ok {
+		return false
+	}
+
+	// only look for a new path once the target has moved
+	goal := target.hitboxBox()
+	path := e.Actor.path
+	if len(path) == 0 || path[len(path)-1] != (pathfinding.Point{X: goal.X, Y: goal.Y}) {
+		path, ok = gs.findPath(e, target.Position.X, target.Position.Y)
+	}
+	if ok {
+		e.Actor.path = path
+		gs.followPath(e)
+		return true
+	}
+
+	// no path, for example outside the map, so head straight for them
+	x, y := entityCenter(e)
+	targetX, targetY := entityCenter(target)
+	gs.walk(e, directionToward(x, y, targetX, targetY))
+	return true
+}
+
+// attackAction turns to face the target and attacks if they are within reach of the npc's weapon
+// it still succeeds while the npc waits for its swing or stamina, so it holds its ground
+func attackAction(gs *gameState, e *entity) bool {
+	target, ok := gs.getTarget(e)
+	if !ok {
+		return false
+	}
+
+	x, y := entityCenter(e)
+	targetX, targetY := entityCenter(target)
+	facing := e.Actor.Facing
+	e.Actor.Facing = directionToward(x, y, targetX, targetY)
+
+	for _, hit := range gs.attackTargets(e) {
+		if hit.ID == target.ID {
+			gs.attack(e)
+			return true
+		}
+	}
+
+	// out of reach, so don't turn after all
+	e.Actor.Facing = facing
+	return false
+}
+
+func fleeAction(gs *gameState, e *entity) bool {
+	target, ok := gs.getTarget(e)
+	if !ok {
+		return false
+	}
+
+	x, y := entityCenter(e)
+	targetX, targetY := entityCenter(target)
+	gs.walk(e, oppositeDirection(directionToward(x, y, targetX, targetY)))
+	return true
+}
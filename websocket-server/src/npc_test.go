@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNPCPatrolsWithNoPlayersNearby(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	npc := gs.addNPC("enemy1", "grunt", 300, 300)
+
+	// the npc starts on its first patrol point, so heads for the next one
+	aiSystem(gs)
+	require.Equal(t, "patrol", npc.AI.Action)
+	require.Equal(t, 302, npc.Position.X)
+	require.Equal(t, "right", npc.Actor.Facing)
+}
+
+func TestNPCChasesPlayerInSight(t *testing.T) {
+	gs := newTestGameState(player{X: 420, Y: 300, Name: "player1", Health: 100, Facing: "left", Skin: "skin1"})
+	npc := gs.addNPC("enemy1", "grunt", 300, 300)
+
+	aiSystem(gs)
+	require.Equal(t, "chase", npc.AI.Action)
+	require.Equal(t, 302, npc.Position.X)
+	require.Equal(t, 100, getTestPlayer(t, gs, "player1").Health)
+}
+
+func TestNPCAttacksPlayerInReach(t *testing.T) {
+	gs := newTestGameState(player{X: 300, Y: 300 + playerSpriteHeight + 5, Name: "player1", Health: 100, Facing: "up", Skin: "skin1"})
+	npc := gs.addNPC("enemy1", "grunt", 300, 300)
+	npc.Actor.Facing = "left"
+
+	aiSystem(gs)
+	require.Equal(t, "attack", npc.AI.Action)
+	require.Equal(t, "down", npc.Actor.Facing)
+	require.True(t, npc.Actor.IsAttacking)
+	require.Equal(t, 100-getWeapon(defaultWeapon).Damage, getTestPlayer(t, gs, "player1").Health)
+
+	// the npc holds its ground while it waits for its swing to finish
+	aiSystem(gs)
+	require.Equal(t, "attack", npc.AI.Action)
+	require.Equal(t, 300, npc.Position.Y)
+	require.Equal(t, 100-getWeapon(defaultWeapon).Damage, getTestPlayer(t, gs, "player1").Health)
+}
+
+func TestNPCFleesAtLowHealth(t *testing.T) {
+	gs := newTestGameState(player{X: 400, Y: 300, Name: "player1", Health: 100, Facing: "left", Skin: "skin1"})
+	npc := gs.addNPC("enemy1", "grunt", 300, 300)
+	npc.Health.Current = 20
+
+	aiSystem(gs)
+	require.Equal(t, "flee", npc.AI.Action)
+	require.Equal(t, 298, npc.Position.X)
+}
+
+func TestNPCIgnoresDeadPlayers(t *testing.T) {
+	gs := newTestGameState(player{X: 400, Y: 300, Name: "player1", Health: 0, Facing: "left", Skin: "skin1"})
+	npc := gs.addNPC("enemy1", "sentry", 340, 300)
+
+	aiSystem(gs)
+	require.Equal(t, "idle", npc.AI.Action)
+}
+
+func TestDeadNPCsAreRemoved(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	npc := gs.addNPC("enemy1", "grunt", 300, 300)
+	p, _ := gs.getPlayer("player1")
+	p.Health.Current = 0
+
+	gs.damage(npc, 100)
+	deathSystem(gs)
+	_, err := gs.getEntity(npc.ID)
+	require.Error(t, err)
+
+	// dead players stay in the game
+	_, err = gs.getPlayer("player1")
+	require.NoError(t, err)
+}
+
+var parseBehaviorsTestCases = []struct {
+	Name  string
+	JSON  string
+	Error string
+}{
+	{
+		Name:  "unknownNodeType",
+		JSON:  `{"npc": {"type": "parallel"}}`,
+		Error: `behavior "npc": unknown node type "parallel"`,
+	},
+	{
+		Name:  "emptySelector",
+		JSON:  `{"npc": {"type": "selector"}}`,
+		Error: `behavior "npc": selector must have children`,
+	},
+	{
+		Name:  "unknownAction",
+		JSON:  `{"npc": {"type": "sequence", "children": [{"type": "action", "name": "dance"}]}}`,
+		Error: `behavior "npc": unknown action "dance"`,
+	},
+	{
+		Name:  "unknownCondition",
+		JSON:  `{"npc": {"type": "sequence", "children": [{"type": "condition", "name": "isHungry"}]}}`,
+		Error: `behavior "npc": unknown condition "isHungry"`,
+	},
+	{
+		Name: "valid",
+		JSON: `{"npc": {"type": "action", "name": "idle"}}`,
+	},
+}
+
+func TestParseBehaviors(t *testing.T) {
+	for _, tc := range parseBehaviorsTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := parseBehaviors([]byte(tc.JSON))
+			if tc.Error == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.Error)
+		})
+	}
+}
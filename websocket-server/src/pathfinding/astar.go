@@ -0,0 +1,107 @@
+package pathfinding
+
+import "container/heap"
+
+type openCell struct {
+	cell  int
+	score int
+}
+
+// openSet is a min heap of cells to visit, lowest estimated cost first
+type openSet []openCell
+
+func (o openSet) Len() int { return len(o) }
+func (o openSet) Less(i, j int) bool {
+	return o[i].score < o[j].score
+}
+func (o openSet) Swap(i, j int) { o[i], o[j] = o[j], o[i] }
+func (o *openSet) Push(x any)   { *o = append(*o, x.(openCell)) }
+func (o *openSet) Pop() any {
+	old := *o
+	last := old[len(old)-1]
+	*o = old[:len(old)-1]
+	return last
+}
+
+// search runs A* from start to goal, moving in the four directions actors can walk in
+// it returns the cells along the path after start, ending with goal
+func (g *Grid) search(start, goal int) ([]int, bool) {
+	if start == goal {
+		return []int{goal}, true
+	}
+
+	cost := make([]int, len(g.blocked))
+	cameFrom := make([]int, len(g.blocked))
+	for i := range cost {
+		cost[i] = -1
+	}
+	cost[start] = 0
+	cameFrom[start] = start
+
+	open := &openSet{{cell: start, score: g.heuristic(start, goal)}}
+	for open.Len() > 0 {
+		current := heap.Pop(open).(openCell).cell
+		if current == goal {
+			return g.reconstruct(cameFrom, start, goal), true
+		}
+
+		for _, next := range g.neighbours(current) {
+			if !g.walkable(next) {
+				continue
+			}
+			nextCost := cost[current] + 1
+			if cost[next] != -1 && nextCost >= cost[next] {
+				continue
+			}
+			cost[next] = nextCost
+			cameFrom[next] = current
+			heap.Push(open, openCell{cell: next, score: nextCost + g.heuristic(next, goal)})
+		}
+	}
+
+	return nil, false
+}
+
+// heuristic is the manhattan distance between cells, which never overestimates on a four way grid
+func (g *Grid) heuristic(from, to int) int {
+	dx := from%g.columns - to%g.columns
+	dy := from/g.columns - to/g.columns
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	return dx + dy
+}
+
+func (g *Grid) neighbours(cell int) []int {
+	column := cell % g.columns
+	row := cell / g.columns
+	neighbours := make([]int, 0, 4)
+	if row > 0 {
+		neighbours = append(neighbours, cell-g.columns)
+	}
+	if row < g.rows-1 {
+		neighbours = append(neighbours, cell+g.columns)
+	}
+	if column > 0 {
+		neighbours = append(neighbours, cell-1)
+	}
+	if column < g.columns-1 {
+		neighbours = append(neighbours, cell+1)
+	}
+	return neighbours
+}
+
+func (g *Grid) reconstruct(cameFrom []int, start, goal int) []int {
+	path := []int{}
+	for cell := goal; cell != start; cell = cameFrom[cell] {
+		path = append(path, cell)
+	}
+	// reverse so the path runs from start to goal
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
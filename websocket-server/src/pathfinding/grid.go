@@ -0,0 +1,171 @@
+// Package pathfinding finds walkable paths around obstacles using A* on a grid.
+//
+// Positions are the top left corner of the agent's hitbox, and every obstacle
+// is grown by the agent's size when the grid is built, so any position inside
+// a walkable cell keeps the whole agent clear of obstacles.
+package pathfinding
+
+// maxCachedPaths bounds the path cache, it is emptied when full
+const maxCachedPaths = 1024
+
+type Rect struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+type Point struct {
+	X int
+	Y int
+}
+
+type Grid struct {
+	bounds      Rect
+	cellSize    int
+	columns     int
+	rows        int
+	agentWidth  int
+	agentHeight int
+
+	// blocked cells are covered by the map's walls
+	blocked []bool
+
+	// cache holds the turning points of paths between cells, without the final destination
+	cache map[cacheKey][]Point
+}
+
+type cacheKey struct {
+	from int
+	to   int
+}
+
+// NewGrid builds a navigation grid covering bounds, for an agent of the given size
+// cellSize should be no bigger than the agent so paths don't skip over narrow walls
+func NewGrid(bounds Rect, cellSize int, walls []Rect, agentWidth, agentHeight int) *Grid {
+	if cellSize <= 0 {
+		cellSize = 1
+	}
+	columns := (bounds.Width + cellSize - 1) / cellSize
+	rows := (bounds.Height + cellSize - 1) / cellSize
+
+	g := &Grid{
+		bounds:      bounds,
+		cellSize:    cellSize,
+		columns:     columns,
+		rows:        rows,
+		agentWidth:  agentWidth,
+		agentHeight: agentHeight,
+		blocked:     make([]bool, columns*rows),
+		cache:       map[cacheKey][]Point{},
+	}
+	for _, wall := range walls {
+		for _, cell := range g.cellsBlockedBy(wall) {
+			g.blocked[cell] = true
+		}
+	}
+	return g
+}
+
+// Walkable reports whether the agent can stand at p
+func (g *Grid) Walkable(p Point) bool {
+	cell, ok := g.cellAt(p)
+	return ok && g.walkable(cell)
+}
+
+// FindPath returns the waypoints to walk through to get from one position to the other,
+// not including from and ending at to. Waypoints are only kept where the path turns.
+func (g *Grid) FindPath(from, to Point) ([]Point, bool) {
+	start, ok := g.cellAt(from)
+	if !ok {
+		return nil, false
+	}
+	goal, ok := g.cellAt(to)
+	if !ok || !g.walkable(goal) {
+		return nil, false
+	}
+
+	key := cacheKey{from: start, to: goal}
+	turns, ok := g.cache[key]
+	if !ok {
+		cells, found := g.search(start, goal)
+		if !found {
+			return nil, false
+		}
+		turns = g.turns(cells)
+		if len(g.cache) >= maxCachedPaths {
+			g.clearCache()
+		}
+		g.cache[key] = turns
+	}
+
+	path := make([]Point, 0, len(turns)+1)
+	path = append(path, turns...)
+	return append(path, to), true
+}
+
+func (g *Grid) clearCache() {
+	g.cache = map[cacheKey][]Point{}
+}
+
+func (g *Grid) walkable(cell int) bool {
+	return !g.blocked[cell]
+}
+
+func (g *Grid) cellAt(p Point) (int, bool) {
+	if p.X < g.bounds.X || p.Y < g.bounds.Y || p.X >= g.bounds.X+g.bounds.Width || p.Y >= g.bounds.Y+g.bounds.Height {
+		return 0, false
+	}
+	column := (p.X - g.bounds.X) / g.cellSize
+	row := (p.Y - g.bounds.Y) / g.cellSize
+	return row*g.columns + column, true
+}
+
+func (g *Grid) cellOrigin(cell int) Point {
+	return Point{
+		X: g.bounds.X + (cell%g.columns)*g.cellSize,
+		Y: g.bounds.Y + (cell/g.columns)*g.cellSize,
+	}
+}
+
+// cellsBlockedBy returns every cell where some position would put the agent inside r
+// r is grown by the agent's size up and to the left, and by one unit all round since touching counts
+func (g *Grid) cellsBlockedBy(r Rect) []int {
+	left := r.X - g.agentWidth - 1
+	top := r.Y - g.agentHeight - 1
+	right := r.X + r.Width + 1
+	bottom := r.Y + r.Height + 1
+
+	firstColumn := max(floorDiv(left-g.bounds.X, g.cellSize), 0)
+	firstRow := max(floorDiv(top-g.bounds.Y, g.cellSize), 0)
+	lastColumn := min(floorDiv(right-g.bounds.X, g.cellSize), g.columns-1)
+	lastRow := min(floorDiv(bottom-g.bounds.Y, g.cellSize), g.rows-1)
+
+	cells := []int{}
+	for row := firstRow; row <= lastRow; row++ {
+		for column := firstColumn; column <= lastColumn; column++ {
+			cells = append(cells, row*g.columns+column)
+		}
+	}
+	return cells
+}
+
+// turns returns the positions where a path of cells changes direction, leaving out the last cell
+func (g *Grid) turns(cells []int) []Point {
+	points := []Point{}
+	for i, cell := range cells[:len(cells)-1] {
+		if i > 0 && cells[i]-cells[i-1] == cells[i+1]-cells[i] {
+			// still going the same way
+			continue
+		}
+		points = append(points, g.cellOrigin(cell))
+	}
+	return points
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 && a%b != 0 {
+		return a/b - 1
+	}
+	return a / b
+}
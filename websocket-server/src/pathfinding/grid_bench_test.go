@@ -0,0 +1,56 @@
+package pathfinding
+
+import "testing"
+
+// largeMap is a 4096 x 4096 map with rows of walls that each leave a gap at alternating ends,
+// so paths from one corner to the other have to zig zag across the whole map
+func largeMap() (Rect, []Rect) {
+	bounds := Rect{X: 0, Y: 0, Width: 4096, Height: 4096}
+	walls := []Rect{}
+	for y := 128; y < bounds.Height; y += 128 {
+		x := 0
+		if (y/128)%2 == 0 {
+			x = 256
+		}
+		walls = append(walls, Rect{X: x, Y: y, Width: bounds.Width - 256, Height: 16})
+	}
+	return bounds, walls
+}
+
+func BenchmarkNewGrid(b *testing.B) {
+	bounds, walls := largeMap()
+	for i := 0; i < b.N; i++ {
+		NewGrid(bounds, 8, walls, 24, 12)
+	}
+}
+
+func BenchmarkFindPathLargeMap(b *testing.B) {
+	bounds, walls := largeMap()
+	g := NewGrid(bounds, 8, walls, 24, 12)
+	from := Point{X: 8, Y: 8}
+	to := Point{X: 4000, Y: 4000}
+	if _, ok := g.FindPath(from, to); !ok {
+		b.Fatal("no path across the map")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.clearCache()
+		g.FindPath(from, to)
+	}
+}
+
+func BenchmarkFindPathCached(b *testing.B) {
+	bounds, walls := largeMap()
+	g := NewGrid(bounds, 8, walls, 24, 12)
+	from := Point{X: 8, Y: 8}
+	to := Point{X: 4000, Y: 4000}
+	if _, ok := g.FindPath(from, to); !ok {
+		b.Fatal("no path across the map")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.FindPath(from, to)
+	}
+}
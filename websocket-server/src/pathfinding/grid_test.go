@@ -0,0 +1,107 @@
+package pathfinding
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testCellSize = 8
+const testAgentWidth = 24
+const testAgentHeight = 12
+
+var testBounds = Rect{X: 0, Y: 0, Width: 400, Height: 400}
+
+// requireWalkablePath checks every step between waypoints, moving one axis at a time like actors do
+func requireWalkablePath(t *testing.T, g *Grid, from Point, path []Point) {
+	current := from
+	for _, waypoint := range path {
+		for current != waypoint {
+			if current.X != waypoint.X {
+				current.X += sign(waypoint.X - current.X)
+			} else {
+				current.Y += sign(waypoint.Y - current.Y)
+			}
+			require.True(t, g.Walkable(current), "%v is not walkable", current)
+		}
+	}
+}
+
+func sign(value int) int {
+	if value < 0 {
+		return -1
+	}
+	return 1
+}
+
+func TestFindPathInOpenSpace(t *testing.T) {
+	g := NewGrid(testBounds, testCellSize, nil, testAgentWidth, testAgentHeight)
+
+	path, ok := g.FindPath(Point{X: 10, Y: 10}, Point{X: 200, Y: 10})
+	require.True(t, ok)
+	require.Equal(t, Point{X: 200, Y: 10}, path[len(path)-1])
+	// a straight line only needs the first and last waypoints
+	require.LessOrEqual(t, len(path), 2)
+}
+
+func TestFindPathAroundWall(t *testing.T) {
+	wall := Rect{X: 100, Y: 0, Width: 20, Height: 300}
+	g := NewGrid(testBounds, testCellSize, []Rect{wall}, testAgentWidth, testAgentHeight)
+
+	from := Point{X: 10, Y: 10}
+	to := Point{X: 200, Y: 10}
+	path, ok := g.FindPath(from, to)
+	require.True(t, ok)
+	require.Equal(t, to, path[len(path)-1])
+	requireWalkablePath(t, g, from, path)
+
+	// the path has to go below the wall
+	lowest := 0
+	for _, p := range path {
+		lowest = max(lowest, p.Y)
+	}
+	require.Greater(t, lowest, wall.Y+wall.Height)
+}
+
+func TestFindPathRespectsAgentSize(t *testing.T) {
+	// the gap between these walls is narrower than the agent
+	walls := []Rect{
+		{X: 100, Y: 0, Width: 20, Height: 190},
+		{X: 100, Y: 200, Width: 20, Height: 200},
+	}
+	g := NewGrid(testBounds, testCellSize, walls, testAgentWidth, testAgentHeight)
+
+	_, ok := g.FindPath(Point{X: 10, Y: 190}, Point{X: 200, Y: 190})
+	require.False(t, ok)
+
+	// a smaller agent fits through
+	g = NewGrid(testBounds, 2, walls, 2, 2)
+	from := Point{X: 10, Y: 194}
+	path, ok := g.FindPath(from, Point{X: 200, Y: 194})
+	require.True(t, ok)
+	requireWalkablePath(t, g, from, path)
+}
+
+func TestFindPathOutsideBounds(t *testing.T) {
+	g := NewGrid(testBounds, testCellSize, nil, testAgentWidth, testAgentHeight)
+
+	_, ok := g.FindPath(Point{X: -10, Y: 10}, Point{X: 200, Y: 10})
+	require.False(t, ok)
+
+	_, ok = g.FindPath(Point{X: 10, Y: 10}, Point{X: 500, Y: 10})
+	require.False(t, ok)
+}
+
+func TestFindPathIsCached(t *testing.T) {
+	g := NewGrid(testBounds, testCellSize, nil, testAgentWidth, testAgentHeight)
+
+	_, ok := g.FindPath(Point{X: 10, Y: 10}, Point{X: 200, Y: 200})
+	require.True(t, ok)
+	require.Len(t, g.cache, 1)
+
+	// positions in the same cells share a cached path, but still end at the exact destination
+	path, ok := g.FindPath(Point{X: 11, Y: 11}, Point{X: 201, Y: 201})
+	require.True(t, ok)
+	require.Len(t, g.cache, 1)
+	require.Equal(t, Point{X: 201, Y: 201}, path[len(path)-1])
+}
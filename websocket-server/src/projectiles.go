@@ -0,0 +1,203 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+//go:embed projectiles.json
+var defaultProjectilesJSON []byte
+
+// projectile kinds that can be fired, replaced at startup if a projectiles file is given
+var projectileKinds = mustParseProjectileKinds(defaultProjectilesJSON)
+
+type projectileKind struct {
+	Name string `json:"-"`
+	// Speed is the distance travelled every refresh
+	Speed int `json:"speed"`
+	// Lifetime is how long the projectile flies for in milliseconds
+	Lifetime int64 `json:"lifetime"`
+	Width    int   `json:"width"`
+	Height   int   `json:"height"`
+}
+
+func loadProjectileKinds(path string) (map[string]projectileKind, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read projectiles: %w", err)
+	}
+	return parseProjectileKinds(data)
+}
+
+func mustParseProjectileKinds(data []byte) map[string]projectileKind {
+	parsed, err := parseProjectileKinds(data)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func parseProjectileKinds(data []byte) (map[string]projectileKind, error) {
+	parsed := map[string]projectileKind{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("parse projectiles: %w", err)
+	}
+
+	names := []string{}
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		k := parsed[name]
+		k.Name = name
+		if k.Speed <= 0 {
+			return nil, fmt.Errorf("projectile %q: speed must be positive", name)
+		}
+		if k.Lifetime <= 0 {
+			return nil, fmt.Errorf("projectile %q: lifetime must be positive", name)
+		}
+		if k.Width <= 0 || k.Height <= 0 {
+			return nil, fmt.Errorf("projectile %q: size must be positive", name)
+		}
+		parsed[name] = k
+	}
+
+	return parsed, nil
+}
+
+// spawnProjectile fires a projectile from the edge of the owner's sprite in the direction they are facing
+func (gs *gameState) spawnProjectile(owner *entity, kindName string, damage int) *entity {
+	kind, ok := projectileKinds[kindName]
+	if !ok {
+		return nil
+	}
+
+	// start centered on the owner, then move out past the edge of their sprite
+	ownerSprite := owner.spriteBox()
+	x := ownerSprite.X + (ownerSprite.Width-kind.Width)/2
+	y := ownerSprite.Y + (ownerSprite.Height-kind.Height)/2
+	velocityX := 0
+	velocityY := 0
+	switch owner.Actor.Facing {
+	case "up":
+		y = ownerSprite.Y - kind.Height
+		velocityY = -kind.Speed
+	case "down":
+		y = ownerSprite.Y + ownerSprite.Height
+		velocityY = kind.Speed
+	case "left":
+		x = ownerSprite.X - kind.Width
+		velocityX = -kind.Speed
+	case "right":
+		x = ownerSprite.X + ownerSprite.Width
+		velocityX = kind.Speed
+	}
+
+	return gs.addEntity(&entity{
+		Kind:     "projectile",
+		Position: &positionComponent{X: x, Y: y},
+		Velocity: &velocityComponent{X: velocityX, Y: velocityY},
+		Hitbox:   &hitboxComponent{Width: kind.Width, Height: kind.Height},
+		Sprite:   &spriteComponent{Width: kind.Width, Height: kind.Height, Skin: kind.Name},
+		Projectile: &projectileComponent{
+			Kind:   kind.Name,
+			Owner:  owner.ID,
+			team:   owner.Actor.Team,
+			damage: damage,
+		},
+		Lifetime: &lifetimeComponent{expiresAt: gs.now() + kind.Lifetime},
+	})
+}
+
+// projectileSystem moves every projectile along its velocity,
+// removing the ones that collided with a wall or something with health
+func projectileSystem(gs *gameState) {
+	for _, e := range gs.entities {
+		if e.Projectile == nil {
+			continue
+		}
+
+		// sweep from the old position to the new one so fast projectiles can't pass through things
+		from := e.hitboxBox()
+		e.Position.X += e.Velocity.X
+		e.Position.Y += e.Velocity.Y
+		swept := sweptBoundingBox(from, e.hitboxBox())
+
+		if gs.collidesWithWall(swept) {
+			gs.removeEntity(e.ID)
+			continue
+		}
+
+		target, hit := gs.projectileHit(e, from, swept)
+		if hit {
+			// the owner may be gone by the time it lands, then nobody gets the kill
+			owner, _ := gs.getEntity(e.Projectile.Owner)
+			gs.hit(owner, target, e.Projectile.damage)
+			for _, effect := range e.Projectile.effects {
+				gs.applyEffect(target, effect)
+			}
+			gs.removeEntity(e.ID)
+		}
+	}
+}
+
+// projectileHit returns the first entity with health along the projectile's path
+func (gs *gameState) projectileHit(p *entity, from, swept boundingBox) (*entity, bool) {
+	var hitEntity *entity
+	hitDistance := -1
+	for _, other := range gs.entities {
+		if other.ID == p.Projectile.Owner || other.ID == p.ID {
+			continue
+		}
+		if other.Health == nil || other.Sprite == nil || other.isDodging() || other.isDead() {
+			continue
+		}
+		if !gs.canHurt(p.Projectile.team, other) {
+			continue
+		}
+
+		sprite := other.spriteBox()
+		if !boxesOverlap(swept, sprite) {
+			continue
+		}
+
+		dx := sprite.X + sprite.Width/2 - (from.X + from.Width/2)
+		dy := sprite.Y + sprite.Height/2 - (from.Y + from.Height/2)
+		distance := dx*dx + dy*dy
+		if hitDistance == -1 || distance < hitDistance {
+			hitEntity = other
+			hitDistance = distance
+		}
+	}
+	return hitEntity, hitEntity != nil
+}
+
+func (gs *gameState) collidesWithWall(box boundingBox) bool {
+	for _, wall := range gs.Walls {
+		if boxesOverlap(box, wall) {
+			return true
+		}
+	}
+	return false
+}
+
+func boxesOverlap(a, b boundingBox) bool {
+	return a.X+a.Width >= b.X && a.X <= b.X+b.Width && a.Y+a.Height >= b.Y && a.Y <= b.Y+b.Height
+}
+
+// sweptBoundingBox returns the box covering both positions
+func sweptBoundingBox(from, to boundingBox) boundingBox {
+	x := min(from.X, to.X)
+	y := min(from.Y, to.Y)
+	return boundingBox{
+		X:      x,
+		Y:      y,
+		Width:  max(from.X+from.Width, to.X+to.Width) - x,
+		Height: max(from.Y+from.Height, to.Y+to.Height) - y,
+	}
+}
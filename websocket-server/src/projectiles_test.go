@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// getTestProjectiles returns every projectile entity
+func getTestProjectiles(gs *gameState) []*entity {
+	projectiles := []*entity{}
+	for _, e := range gs.entities {
+		if e.Projectile != nil {
+			projectiles = append(projectiles, e)
+		}
+	}
+	return projectiles
+}
+
+func TestProjectileHitsPlayer(t *testing.T) {
+	archer := testPlayer1FacingRight
+	archer.Weapon = "bow"
+	gs := newTestGameState(
+		archer,
+		player{X: 100, Y: 0, Name: "player2", Health: 100, Facing: "left", Skin: "skin2"},
+	)
+	owner, _ := gs.getPlayer("player1")
+
+	gs.playerAttack("player1")
+	projectiles := getTestProjectiles(gs)
+	require.Len(t, projectiles, 1)
+	require.Equal(t, "arrow", projectiles[0].Projectile.Kind)
+	require.Equal(t, owner.ID, projectiles[0].Projectile.Owner)
+	require.Equal(t, playerSpriteWidth, projectiles[0].Position.X)
+
+	// the arrow flies until it reaches player2
+	for i := 0; i < 10 && len(getTestProjectiles(gs)) > 0; i++ {
+		projectileSystem(gs)
+	}
+	require.Empty(t, getTestProjectiles(gs))
+	require.Equal(t, 100-getWeapon("bow").Damage, getTestPlayer(t, gs, "player2").Health)
+	require.Equal(t, 100, getTestPlayer(t, gs, "player1").Health)
+}
+
+func TestProjectileStopsAtWall(t *testing.T) {
+	gs := newTestGameState(
+		testPlayer1FacingRight,
+		player{X: 100, Y: 0, Name: "player2", Health: 100, Facing: "left", Skin: "skin2"},
+	)
+	gs.Walls = []boundingBox{{X: 70, Y: -20, Width: 4, Height: 100}}
+	owner, _ := gs.getPlayer("player1")
+
+	gs.spawnProjectile(owner, "arrow", 10)
+	for i := 0; i < 10; i++ {
+		projectileSystem(gs)
+	}
+	require.Empty(t, getTestProjectiles(gs))
+	require.Equal(t, 100, getTestPlayer(t, gs, "player2").Health)
+}
+
+func TestProjectileMissesDodgingPlayer(t *testing.T) {
+	gs := newTestGameState(
+		testPlayer1FacingRight,
+		player{X: 60, Y: 0, Name: "player2", Health: 100, Facing: "left", Skin: "skin2", IsDodging: true},
+	)
+	owner, _ := gs.getPlayer("player1")
+
+	gs.spawnProjectile(owner, "arrow", 10)
+	projectileSystem(gs)
+	require.Len(t, getTestProjectiles(gs), 1)
+	require.Equal(t, 100, getTestPlayer(t, gs, "player2").Health)
+}
+
+func TestProjectileExpires(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingUp)
+	owner, _ := gs.getPlayer("player1")
+
+	gs.spawnProjectile(owner, "fireball", 10)
+	gs.refresh()
+	projectiles := getTestProjectiles(gs)
+	require.Len(t, projectiles, 1)
+	require.Equal(t, -projectileKinds["fireball"].Height-projectileKinds["fireball"].Speed, projectiles[0].Position.Y)
+
+	projectiles[0].Lifetime.expiresAt = time.Now().UnixMilli() - 1
+	gs.refresh()
+	require.Empty(t, getTestProjectiles(gs))
+}
+
+func TestPlayerCannotWalkIntoWall(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+	hitbox := p.hitboxBox()
+	gs.Walls = []boundingBox{{X: hitbox.X + hitbox.Width + 2, Y: -100, Width: 10, Height: 200}}
+
+	gs.playerWalk("player1", "right")
+	require.Equal(t, 0, getTestPlayer(t, gs, "player1").X)
+
+	gs.playerWalk("player1", "left")
+	require.Equal(t, -2, getTestPlayer(t, gs, "player1").X)
+}
@@ -0,0 +1,41 @@
+package main
+
+// regenRules decide how much health actors recover on their own every refresh
+// resources regenerate according to their own pools
+type regenRules struct {
+	Health int
+	// HealthDelay is how long after taking damage health starts coming back, in milliseconds
+	HealthDelay int64
+}
+
+var defaultRegenRules = regenRules{
+	Health:      0,
+	HealthDelay: 5000,
+}
+
+// heal restores the entity's health, up to its max
+func (gs *gameState) heal(e *entity, amount int) {
+	if e.Health == nil || e.Health.Current <= 0 {
+		return
+	}
+	e.Health.Current = min(e.Health.Current+amount, e.Health.Max)
+}
+
+// regenSystem applies the passive health regen rules to every living actor
+func regenSystem(gs *gameState) {
+	now := gs.now()
+	for _, e := range gs.entities {
+		if e.Actor == nil || e.Health == nil || e.Health.Current <= 0 {
+			continue
+		}
+
+		health := 0
+		if now-e.Health.lastDamaged > gs.regen.HealthDelay {
+			health = gs.regen.Health
+		}
+
+		if health > 0 {
+			gs.heal(e, health)
+		}
+	}
+}
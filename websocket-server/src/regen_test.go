@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassiveRegen(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   regenRules
+		damaged time.Duration
+		health  int
+	}{
+		{"default", defaultRegenRules, time.Minute, 50},
+		{"no regen", regenRules{}, time.Minute, 50},
+		{"health", regenRules{Health: 2}, time.Minute, 52},
+		{"recently damaged", regenRules{Health: 2, HealthDelay: 5000}, time.Second, 50},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gs := newTestGameState(testPlayer1FacingRight)
+			gs.regen = test.rules
+			p, _ := gs.getPlayer("player1")
+			p.Health.Current = 50
+			p.Health.lastDamaged = time.Now().Add(-test.damaged).UnixMilli()
+
+			regenSystem(gs)
+			require.Equal(t, test.health, p.Health.Current)
+		})
+	}
+}
+
+func TestRegenStopsAtMax(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	gs.regen = regenRules{Health: 5}
+	p, _ := gs.getPlayer("player1")
+	p.Health.Current = p.Health.Max - 1
+
+	regenSystem(gs)
+	require.Equal(t, p.Health.Max, p.Health.Current)
+
+	// the dead don't recover
+	p.Health.Current = 0
+	regenSystem(gs)
+	require.Equal(t, 0, p.Health.Current)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// replays are gzipped json lines, a replayHeader followed by every event the room handled
+
+// replayHeader is the first line of a replay, describing the room being recorded
+// it holds how the room was set up, so playback builds the same room whatever flags it was started with
+type replayHeader struct {
+	Room     string       `json:"room"`
+	Started  int64        `json:"started"`
+	Settings roomSettings `json:"settings"`
+	// Seed is the room's random seed, so playback makes the same random choices
+	Seed    int64         `json:"seed"`
+	Teams   []team        `json:"teams"`
+	Walls   []boundingBox `json:"walls"`
+	Hazards []hazard      `json:"hazards"`
+	Bounds  boundingBox   `json:"bounds"`
+	Regen   regenRules    `json:"regen"`
+	// Enemies is how many npc enemies the room was opened with
+	Enemies  int           `json:"enemies"`
+	Spawners []itemSpawner `json:"spawners"`
+	// Tuning is what the room played with when recording started
+	Tuning tuning `json:"tuning"`
+}
+
+// newReplayHeader describes the room as it is now, which should be before anything has happened in it
+func newReplayHeader(room string, gs *gameState) replayHeader {
+	return replayHeader{
+		Room:     room,
+		Started:  gs.now(),
+		Settings: gs.Settings,
+		Seed:     gs.rngSeed,
+		Teams:    gs.Teams,
+		Walls:    gs.Walls,
+		Hazards:  gs.Hazards,
+		Bounds:   gs.Bounds,
+		Regen:    gs.regen,
+		Enemies:  gs.enemies,
+		Spawners: gs.spawners,
+		Tuning:   gs.tuning,
+	}
+}
+
+// newState builds the room the way it was when recording started, on the replay's clock
+// the steps are in the same order rooms are opened in, so random choices come out the same
+func (h replayHeader) newState() (*gameState, *manualClock, error) {
+	c := &manualClock{time: h.Started}
+	gs := newGameState()
+	gs.clock = c
+	gs.seed(h.Seed)
+	gs.regen = h.Regen
+	// replays recorded before the room's setup was saved keep the defaults for what they don't have
+	if h.Bounds.Width > 0 && h.Bounds.Height > 0 {
+		gs.Bounds = h.Bounds
+	}
+	if h.Walls != nil {
+		gs.Walls = h.Walls
+	}
+	if h.Hazards != nil {
+		gs.Hazards = h.Hazards
+	}
+	if h.Settings.Mode != "" {
+		err := gs.setMode(h.Settings.Mode)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	gs.Settings = h.Settings
+	if h.Teams != nil {
+		gs.Teams = h.Teams
+	}
+	gs.spawnEnemies(h.Enemies)
+	gs.setSpawners(h.Spawners)
+	if h.Tuning.WalkDistance > 0 {
+		gs.setTuning(h.Tuning)
+	}
+	return gs, c, nil
+}
+
+// replayEvent is an event and when it was handled, in milliseconds since recording started
+// refresh events are recorded too, so the replay keeps the room's tick timing
+// Tuning is set instead of the event when the room's tuning was reloaded
+type replayEvent struct {
+	T      int64     `json:"t"`
+	Event  gameEvent `json:"event"`
+	Tuning *tuning   `json:"tuning,omitempty"`
+}
+
+// replayRecorder writes a room's events to a replay file as they are handled
+type replayRecorder struct {
+	file    *os.File
+	gz      *gzip.Writer
+	enc     *json.Encoder
+	started int64
+}
+
+// newReplayRecorder starts a replay of the room in dir, named after the room and when it started
+func newReplayRecorder(dir, room string, gs *gameState) (*replayRecorder, error) {
+	header := newReplayHeader(room, gs)
+	started := header.Started
+	// room names come from clients, so they are escaped to keep the file in dir
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d.replay.gz", url.PathEscape(room), started))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create replay: %w", err)
+	}
+
+	gz := gzip.NewWriter(file)
+	r := &replayRecorder{file: file, gz: gz, enc: json.NewEncoder(gz), started: started}
+	err = r.write(header)
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// record adds the event to the replay, now is the room's time when it was handled
+func (r *replayRecorder) record(event gameEvent, now int64) {
+	err := r.write(replayEvent{T: now - r.started, Event: event})
+	if err != nil {
+		log.Println("record replay:", err)
+	}
+}
+
+// recordTuning adds a tuning reload to the replay, now is the room's time when it was reloaded
+func (r *replayRecorder) recordTuning(t tuning, now int64) {
+	err := r.write(replayEvent{T: now - r.started, Tuning: &t})
+	if err != nil {
+		log.Println("record replay:", err)
+	}
+}
+
+// write adds a line to the replay, flushing it so the replay can be played even if the server dies
+func (r *replayRecorder) write(line any) error {
+	err := r.enc.Encode(line)
+	if err != nil {
+		return fmt.Errorf("write replay: %w", err)
+	}
+	return r.gz.Flush()
+}
+
+func (r *replayRecorder) close() {
+	err := r.gz.Close()
+	if err != nil {
+		log.Println("close replay:", err)
+	}
+	err = r.file.Close()
+	if err != nil {
+		log.Println("close replay:", err)
+	}
+}
+
+// loadReplay reads back a replay file
+func loadReplay(path string) (replayHeader, []replayEvent, error) {
+	header := replayHeader{}
+	events := []replayEvent{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return header, nil, fmt.Errorf("read replay: %w", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return header, nil, fmt.Errorf("read replay: %w", err)
+	}
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(nil, 1024*1024)
+	if !scanner.Scan() {
+		return header, nil, fmt.Errorf("read replay: missing header")
+	}
+	err = json.Unmarshal(scanner.Bytes(), &header)
+	if err != nil {
+		return header, nil, fmt.Errorf("parse replay header: %w", err)
+	}
+	for scanner.Scan() {
+		e := replayEvent{}
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return header, nil, fmt.Errorf("parse replay event %d: %w", len(events)+1, err)
+		}
+		events = append(events, e)
+	}
+	// a replay cut off mid line by the server dying still plays up to there
+	if err := scanner.Err(); err != nil {
+		log.Println("read replay:", err)
+	}
+	return header, events, nil
+}
+
+// replayPlayback feeds a replay's events back into a room, speed times faster than they were recorded
+type replayPlayback struct {
+	events []replayEvent
+	next   int
+	// at is how far into the replay playback has got, in milliseconds
+	at    float64
+	speed float64
+	// clock is set to when each event was recorded as it is handled, so the simulation plays out the same
+	clock   *manualClock
+	started int64
+}
+
+// startPlayback rebuilds the room from the replay to re-simulate it, on the replay's clock and random seed
+func (rm *room) startPlayback(header replayHeader, events []replayEvent, speed float64) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	gs, c, err := header.newState()
+	if err != nil {
+		return fmt.Errorf("start playback: %w", err)
+	}
+	rm.state = gs
+	rm.playback = &replayPlayback{events: events, speed: speed, clock: c, started: header.Started}
+	return nil
+}
+
+// play runs the replay at its speed in real time, returning once every event has been handled
+func (rm *room) play() {
+	ticker := time.NewTicker(broadcastInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for now := range ticker.C {
+		if rm.advancePlayback(now.Sub(last)) {
+			return
+		}
+		last = now
+	}
+}
+
+// advancePlayback handles every event due in the next elapsed time, reporting whether the replay is over
+func (rm *room) advancePlayback(elapsed time.Duration) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	p := rm.playback
+	p.at += float64(elapsed.Milliseconds()) * p.speed
+	for p.next < len(p.events) && float64(p.events[p.next].T) <= p.at {
+		e := p.events[p.next]
+		p.clock.time = p.started + e.T
+		if e.Tuning != nil {
+			rm.state.setTuning(*e.Tuning)
+		} else {
+			rm.state.handleEventAt(e.Event, rm.state.now())
+		}
+		p.next++
+	}
+	return p.next == len(p.events)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayRecording(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	rooms.recordDir = t.TempDir()
+	rm := rooms.get("arena")
+
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: testPlayer1FacingRight}}, "player1")
+	for i := 0; i < 3; i++ {
+		rm.handle(gameEvent{Type: "walk", Data: eventData{player: player{Name: "player1", Facing: "down"}}}, "player1")
+		rm.handle(gameEvent{Type: "refresh"}, "player1")
+	}
+
+	// replays can be read while they are still being recorded
+	paths, err := filepath.Glob(filepath.Join(rooms.recordDir, "arena-*.replay.gz"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	header, events, err := loadReplay(paths[0])
+	require.NoError(t, err)
+	require.Equal(t, "arena", header.Room)
+	require.Len(t, events, 7)
+	require.Equal(t, "join", events[0].Event.Type)
+	require.Equal(t, "player1", events[0].Event.Data.Name)
+	rm.recorder.close()
+
+	// playing the replay back ends up where the recording did
+	played := newRoomRegistry(newGameState).get("arena")
+	require.NoError(t, played.startPlayback(header, events, 1))
+	require.True(t, played.advancePlayback(time.Minute))
+	p, err := played.state.getPlayer("player1")
+	require.NoError(t, err)
+	require.Equal(t, 3*playerWalkDistance, p.Position.Y)
+}
+
+func TestReplayNamesStayInTheRecordDir(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	rooms.recordDir = filepath.Join(t.TempDir(), "replays")
+	require.NoError(t, os.Mkdir(rooms.recordDir, 0o755))
+	rm := rooms.get("../escaped")
+	defer rm.recorder.close()
+
+	paths, err := filepath.Glob(filepath.Join(rooms.recordDir, "*.replay.gz"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	require.True(t, strings.HasPrefix(filepath.Base(paths[0]), "..%2Fescaped-"))
+	escaped, err := filepath.Glob(filepath.Join(rooms.recordDir, "..", "escaped-*"))
+	require.NoError(t, err)
+	require.Empty(t, escaped)
+}
+
+func TestReplayPlaybackSpeed(t *testing.T) {
+	events := []replayEvent{
+		{T: 0, Event: gameEvent{Type: "join", Data: eventData{player: testPlayer1FacingRight}}},
+		{T: 1000, Event: gameEvent{Type: "walk", Data: eventData{player: player{Name: "player1", Facing: "down"}}}},
+		{T: 2000, Event: gameEvent{Type: "walk", Data: eventData{player: player{Name: "player1", Facing: "down"}}}},
+	}
+	rm := newRoomRegistry(newGameState).get("")
+	require.NoError(t, rm.startPlayback(replayHeader{Started: 1000}, events, 2))
+
+	require.False(t, rm.advancePlayback(500*time.Millisecond))
+	p, err := rm.state.getPlayer("player1")
+	require.NoError(t, err)
+	require.Equal(t, playerWalkDistance, p.Position.Y)
+
+	// spectators can slow it down
+	s := rm.spectate()
+	defer rm.stopSpectating(s)
+	rm.spectatorEvent(s, gameEvent{Type: "speed", Data: eventData{Speed: 0.5}})
+	require.False(t, rm.advancePlayback(time.Second))
+	require.True(t, rm.advancePlayback(time.Second))
+	require.Equal(t, 2*playerWalkDistance, p.Position.Y)
+
+	// the simulation is on the replay's time
+	require.Equal(t, int64(3000), rm.state.now())
+}
+
+func TestReplayIsDeterministic(t *testing.T) {
+	rooms := newRoomRegistry(func() *gameState {
+		gs := newGameState()
+		gs.clock = &manualClock{time: 5000}
+		return gs
+	})
+	rooms.recordDir = t.TempDir()
+	rm := rooms.get("arena")
+	c := rm.state.clock.(*manualClock)
+
+	// a fight with timing rules, the second attack is during the first swing and the third isn't
+	attacker := testPlayer1FacingRight
+	attacker.Weapon = "hammer"
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: attacker}}, "player1")
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{X: playerSpriteWidth + 5, Name: "player2", Facing: "left"}}}, "player2")
+	for i := 0; i < 3; i++ {
+		rm.handle(gameEvent{Type: "attack", Data: eventData{player: player{Name: "player1"}}}, "player1")
+		c.advance(getWeapon("hammer").SwingDuration / 2)
+		rm.handle(gameEvent{Type: "refresh"}, "player1")
+		c.advance(getWeapon("hammer").SwingDuration / 2)
+	}
+	rm.handle(gameEvent{Type: "refresh"}, "player1")
+	rm.recorder.close()
+	expected := rm.state.getPlayers()
+
+	paths, err := filepath.Glob(filepath.Join(rooms.recordDir, "arena-*.replay.gz"))
+	require.NoError(t, err)
+	header, events, err := loadReplay(paths[0])
+	require.NoError(t, err)
+	require.Equal(t, rm.state.rngSeed, header.Seed)
+
+	// however fast it is played back
+	for _, speed := range []float64{0.5, 1, 8} {
+		played := newRoomRegistry(newGameState).get("arena")
+		require.NoError(t, played.startPlayback(header, events, speed))
+		for !played.advancePlayback(broadcastInterval) {
+		}
+		require.Equal(t, expected, played.state.getPlayers(), speed)
+		require.Equal(t, rm.state.tick, played.state.tick, speed)
+	}
+}
+
+// tickingClock moves on every time it is read, the way the system clock does while an event is being handled
+type tickingClock struct {
+	manualClock
+}
+
+func (c *tickingClock) now() int64 {
+	c.time++
+	return c.time
+}
+
+func TestReplayRecordsTheTimeEventsSee(t *testing.T) {
+	rooms := newRoomRegistry(func() *gameState {
+		gs := newGameState()
+		gs.clock = &tickingClock{manualClock{time: 5000}}
+		return gs
+	})
+	rooms.recordDir = t.TempDir()
+	rm := rooms.get("arena")
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: testPlayer1FacingRight}}, "player1")
+	rm.handle(gameEvent{Type: "dodge", Data: eventData{player: player{Name: "player1"}}}, "player1")
+	rm.recorder.close()
+
+	paths, err := filepath.Glob(filepath.Join(rooms.recordDir, "arena-*.replay.gz"))
+	require.NoError(t, err)
+	header, events, err := loadReplay(paths[0])
+	require.NoError(t, err)
+	require.Equal(t, "dodge", events[1].Event.Type)
+	p, err := rm.state.getPlayer("player1")
+	require.NoError(t, err)
+	require.Equal(t, header.Started+events[1].T, p.Actor.lastDodge)
+}
+
+func TestReplayRebuildsTheRoom(t *testing.T) {
+	rooms := newRoomRegistry(func() *gameState {
+		gs := newGameState()
+		gs.clock = &manualClock{time: 5000}
+		gs.regen = regenRules{Health: 5, HealthDelay: 100}
+		gs.Teams = defaultTeams(gs.Bounds)
+		gs.Walls = []boundingBox{{X: 500, Y: 500, Width: 10, Height: 10}}
+		gs.spawnEnemies(2)
+		gs.setSpawners([]itemSpawner{{Item: "coin", Count: 1, X: 1500, Y: 900}})
+		return gs
+	})
+	rooms.recordDir = t.TempDir()
+	rm := rooms.get("arena")
+	c := rm.state.clock.(*manualClock)
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{X: 100, Y: 300 + playerSpriteHeight, Name: "player1", Facing: "up", Health: 100}}}, "player1")
+	for i := 0; i < 20; i++ {
+		c.advance(broadcastInterval.Milliseconds())
+		rm.handle(gameEvent{Type: "refresh"}, "player1")
+	}
+	rm.recorder.close()
+
+	paths, err := filepath.Glob(filepath.Join(rooms.recordDir, "arena-*.replay.gz"))
+	require.NoError(t, err)
+	header, events, err := loadReplay(paths[0])
+	require.NoError(t, err)
+	require.Equal(t, 2, header.Enemies)
+
+	// played back on a server with none of the same setup
+	played := newRoomRegistry(newGameState).get("arena")
+	require.NoError(t, played.startPlayback(header, events, 1))
+	for !played.advancePlayback(broadcastInterval) {
+	}
+	require.Equal(t, rm.state.regen, played.state.regen)
+	require.Equal(t, rm.state.Teams, played.state.Teams)
+	require.Equal(t, rm.state.Walls, played.state.Walls)
+	require.Equal(t, rm.state.getPlayers(), played.state.getPlayers())
+	require.Len(t, played.state.entities, len(rm.state.entities))
+	for i, e := range rm.state.entities {
+		require.Equal(t, *e.Position, *played.state.entities[i].Position)
+		require.Equal(t, e.Health, played.state.entities[i].Health)
+	}
+	require.Len(t, getTestItems(played.state), 1)
+}
+
+func TestLoadReplayErrors(t *testing.T) {
+	_, _, err := loadReplay(filepath.Join(t.TempDir(), "missing.replay.gz"))
+	require.ErrorContains(t, err, "read replay:")
+}
+
+func TestReplayRecordsTuningReloads(t *testing.T) {
+	rooms := newRoomRegistry(func() *gameState {
+		gs := newGameState()
+		gs.clock = &manualClock{time: 5000}
+		return gs
+	})
+	rooms.recordDir = t.TempDir()
+	rm := rooms.get("arena")
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{X: 100, Y: 100, Name: "player1", Facing: "down"}}}, "player1")
+	rm.handle(gameEvent{Type: "walk", Data: eventData{player: player{Name: "player1", Facing: "down"}}}, "player1")
+	config, err := parseTuning([]byte("walkDistance: 30"))
+	require.NoError(t, err)
+	rooms.setTuning(config)
+	rm.state.clock.(*manualClock).advance(1000)
+	rm.handle(gameEvent{Type: "walk", Data: eventData{player: player{Name: "player1", Facing: "down"}}}, "player1")
+	rm.recorder.close()
+	expected := rm.state.getPlayers()
+	require.Equal(t, 100+playerWalkDistance+30, expected[0].Y)
+
+	paths, err := filepath.Glob(filepath.Join(rooms.recordDir, "arena-*.replay.gz"))
+	require.NoError(t, err)
+	header, events, err := loadReplay(paths[0])
+	require.NoError(t, err)
+
+	// playback walks the same distances, changing tuning where the room did
+	played := newRoomRegistry(newGameState).get("arena")
+	require.NoError(t, played.startPlayback(header, events, 1))
+	for !played.advancePlayback(broadcastInterval) {
+	}
+	require.Equal(t, expected, played.state.getPlayers())
+	require.Equal(t, 30, played.state.tuning.WalkDistance)
+}
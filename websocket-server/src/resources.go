@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultStamina is the stamina pool actors without a class get
+var defaultStamina = resourcePoolKind{Max: 100, Regen: 1}
+
+// resourcePoolKind describes a pool of something actors spend on actions, like stamina or mana
+type resourcePoolKind struct {
+	Max int `json:"max"`
+	// Regen is added every refresh, once RegenDelay milliseconds have passed since the pool was last spent from
+	// negative regen drains the pool instead
+	Regen      int   `json:"regen"`
+	RegenDelay int64 `json:"regenDelay"`
+}
+
+// resourcePool is an actor's pool of a resource, they start full
+type resourcePool struct {
+	resourcePoolKind
+	Current   int `json:"current"`
+	lastSpent int64
+}
+
+// resourceCost is how much of each resource an action takes
+type resourceCost map[string]int
+
+func newResourcePool(kind resourcePoolKind) *resourcePool {
+	return &resourcePool{resourcePoolKind: kind, Current: kind.Max}
+}
+
+func (k resourcePoolKind) validate() error {
+	if k.Max <= 0 {
+		return fmt.Errorf("max must be positive")
+	}
+	if k.RegenDelay < 0 {
+		return fmt.Errorf("regen delay cannot be negative")
+	}
+	return nil
+}
+
+// validate checks none of the amounts are negative, in resource name order
+func (c resourceCost) validate() error {
+	for _, name := range c.names() {
+		if c[name] < 0 {
+			return fmt.Errorf("%s cost cannot be negative", name)
+		}
+	}
+	return nil
+}
+
+func (c resourceCost) names() []string {
+	return sortedKeys(c)
+}
+
+// sortedKeys returns the keys of a map in order, so validation errors are reported consistently
+func sortedKeys[V any](m map[string]V) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resource returns how much of the named resource the actor has, 0 if they don't have the pool
+func (e *entity) resource(name string) int {
+	pool, ok := e.Actor.Resources[name]
+	if !ok {
+		return 0
+	}
+	return pool.Current
+}
+
+// canAfford reports whether the actor has enough of every resource in the cost
+func (gs *gameState) canAfford(e *entity, cost resourceCost) bool {
+	for name, amount := range cost {
+		if amount == 0 {
+			continue
+		}
+		pool, ok := e.Actor.Resources[name]
+		if !ok || pool.Current < amount {
+			return false
+		}
+	}
+	return true
+}
+
+// spend takes the cost out of the actor's pools, which then wait their regen delay before recovering
+func (gs *gameState) spend(e *entity, cost resourceCost) {
+	now := gs.now()
+	for name, amount := range cost {
+		pool, ok := e.Actor.Resources[name]
+		if !ok || amount == 0 {
+			continue
+		}
+		pool.Current = max(pool.Current-amount, 0)
+		pool.lastSpent = now
+	}
+}
+
+// restore gives the actor some of the resource back, up to the pool's max
+func (gs *gameState) restore(e *entity, name string, amount int) {
+	pool, ok := e.Actor.Resources[name]
+	if !ok {
+		return
+	}
+	pool.Current = clamp(pool.Current+amount, 0, pool.Max)
+}
+
+// resourceSystem regenerates every living actor's pools
+func resourceSystem(gs *gameState) {
+	now := gs.now()
+	for _, e := range gs.entities {
+		if e.Actor == nil || e.Health == nil || e.Health.Current <= 0 {
+			continue
+		}
+		for _, pool := range e.Actor.Resources {
+			if pool.Regen == 0 || now-pool.lastSpent < pool.RegenDelay {
+				continue
+			}
+			pool.Current = clamp(pool.Current+pool.Regen, 0, pool.Max)
+		}
+	}
+}
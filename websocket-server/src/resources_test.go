@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpendResources(t *testing.T) {
+	gs := newTestGameState(player{Name: "player1", Class: "mage", Facing: "down"})
+	p, _ := gs.getPlayer("player1")
+
+	require.True(t, gs.canAfford(p, resourceCost{"stamina": 100, "mana": 100}))
+	require.False(t, gs.canAfford(p, resourceCost{"stamina": 101}))
+	// pools the actor doesn't have can only be afforded if nothing is spent from them
+	require.False(t, gs.canAfford(p, resourceCost{"rage": 1}))
+	require.True(t, gs.canAfford(p, resourceCost{"rage": 0}))
+
+	gs.spend(p, resourceCost{"stamina": 10, "mana": 30})
+	require.Equal(t, 90, p.resource("stamina"))
+	require.Equal(t, 70, p.resource("mana"))
+	require.Equal(t, 0, p.resource("rage"))
+
+	gs.restore(p, "mana", 50)
+	require.Equal(t, 100, p.resource("mana"))
+}
+
+func TestResourceRegen(t *testing.T) {
+	gs := newTestGameState(player{Name: "player1", Class: "mage", Facing: "down"})
+	p, _ := gs.getPlayer("player1")
+	mana := classes["mage"].Resources["mana"]
+	stamina := classes["mage"].Resources["stamina"]
+
+	gs.spend(p, resourceCost{"stamina": 10, "mana": 10})
+	resourceSystem(gs)
+
+	// mana waits for its regen delay after being spent, stamina doesn't have one
+	require.Equal(t, 90+stamina.Regen, p.resource("stamina"))
+	require.Equal(t, 90, p.resource("mana"))
+
+	p.Actor.Resources["mana"].lastSpent = time.Now().UnixMilli() - mana.RegenDelay
+	resourceSystem(gs)
+	require.Equal(t, 90+mana.Regen, p.resource("mana"))
+
+	// and never goes past the max
+	p.Actor.Resources["mana"].Current = mana.Max
+	resourceSystem(gs)
+	require.Equal(t, mana.Max, p.resource("mana"))
+}
+
+func TestDrainingResources(t *testing.T) {
+	gs := newTestGameState(testPlayer1FacingRight)
+	p, _ := gs.getPlayer("player1")
+	p.Actor.Resources["rage"] = &resourcePool{resourcePoolKind: resourcePoolKind{Max: 50, Regen: -2}, Current: 1}
+
+	resourceSystem(gs)
+	require.Equal(t, 0, p.resource("rage"))
+}
+
+func TestAttacksSpendTheWeaponCost(t *testing.T) {
+	gs := newTestGameState(player{Name: "player1", Class: "mage", Facing: "right"})
+	p, _ := gs.getPlayer("player1")
+	staff := getWeapon("staff")
+
+	p.Actor.Resources["mana"].Current = staff.Cost["mana"] - 1
+	gs.playerAttack("player1")
+	require.Empty(t, getTestProjectiles(gs))
+
+	p.Actor.Resources["mana"].Current = staff.Cost["mana"]
+	gs.playerAttack("player1")
+	require.Len(t, getTestProjectiles(gs), 1)
+	require.Equal(t, 0, p.resource("mana"))
+	require.Equal(t, 100, p.resource("stamina"))
+}
+
+func TestResourcesInSnapshot(t *testing.T) {
+	gs := newTestGameState(player{Name: "player1", Class: "mage", Facing: "down"})
+	p, _ := gs.getPlayer("player1")
+	gs.spend(p, resourceCost{"mana": 25})
+
+	view := p.toPlayer()
+	require.Equal(t, 100, view.Stamina)
+	require.Equal(t, 75, view.Resources["mana"].Current)
+	require.Equal(t, classes["mage"].Resources["mana"], view.Resources["mana"].resourcePoolKind)
+}
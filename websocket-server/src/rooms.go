@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultRoom is the room clients join when they don't ask for one
+const defaultRoom = "default"
+
+// maxRooms is how many rooms can be open at once, rooms are named by clients so this keeps them from opening rooms without end
+// each open room also has its own series of the room players metric
+const maxRooms = 100
+
+var (
+	errTooManyRooms = errors.New("too many rooms are open, join one of them or try again later")
+	errRoomClosed   = errors.New("room is closed")
+)
+
+// hostKickedMessage is what players kicked by the host are told as their connection closes
+const hostKickedMessage = "you were kicked from the room by the host"
+
+// room is one game, players in different rooms never see each other
+// every event is handled with the room locked, since each connection has its own goroutine
+type room struct {
+	name  string
+	mu    sync.Mutex
+	state *gameState
+	// spectators are sent snapshots by the room while any are watching
+	spectators    map[*spectator]bool
+	stopBroadcast chan struct{}
+	// recorder saves every event the room handles, when replays are being recorded
+	recorder *replayRecorder
+	// playback is the replay the room is playing, if it is playing one
+	playback *replayPlayback
+	// conns are the connections playing or watching the room and the player each joined as, empty until they join
+	// they are closed when the server shuts down
+	conns map[*websocket.Conn]string
+	// closing rooms don't let anyone join, closed rooms don't take connections either
+	closing bool
+	closed  bool
+}
+
+// pausedEvents are the events paused rooms still handle
+var pausedEvents = map[string]bool{
+	"join":   true,
+	"chat":   true,
+	"mute":   true,
+	"unmute": true,
+	"leave":  true,
+}
+
+// roomRegistry opens rooms as clients ask for them
+type roomRegistry struct {
+	mu    sync.Mutex
+	rooms map[string]*room
+	// newState builds the game state for each room as it is opened
+	newState func() *gameState
+	// recordDir is where rooms record replays to, empty to not record
+	recordDir string
+	// tuning is what rooms play with, nil to leave them with the default tuning
+	tuning *tuningConfig
+	// closing is set once the server starts shutting down
+	closing bool
+	// bans are the names banned from each room, kept here since rooms are thrown away once they empty
+	bans map[string]map[string]bool
+	// reconnectTimeout is how long players restored after a restart have to reconnect before they are removed
+	reconnectTimeout time.Duration
+}
+
+func newRoomRegistry(newState func() *gameState) *roomRegistry {
+	return &roomRegistry{
+		rooms:            map[string]*room{},
+		bans:             map[string]map[string]bool{},
+		newState:         newState,
+		reconnectTimeout: reconnectTimeout,
+	}
+}
+
+// get returns the named room, opening it if nobody is in it yet
+func (r *roomRegistry) get(name string) *room {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.open(name)
+}
+
+// open returns the named room with the registry locked, opening it if it isn't open
+func (r *roomRegistry) open(name string) *room {
+	if name == "" {
+		name = defaultRoom
+	}
+	rm, ok := r.rooms[name]
+	if !ok {
+		rm = &room{name: name, state: r.newState(), closing: r.closing}
+		r.rooms[name] = rm
+		// the room shares its bans with the registry, so banned players can't wait for it to empty and come back
+		if r.bans[name] == nil {
+			r.bans[name] = map[string]bool{}
+		}
+		rm.state.banned = r.bans[name]
+		if r.tuning != nil {
+			rm.state.setTuning(r.tuning.forRoom(name))
+		}
+		if r.recordDir != "" {
+			recorder, err := newReplayRecorder(r.recordDir, name, rm.state)
+			if err != nil {
+				log.Println("record room:", err)
+			}
+			rm.recorder = recorder
+		}
+	}
+	return rm
+}
+
+// connect tracks a connection to the named room, opening the room if it needs to and there is room for it
+// the registry stays locked so the room can't be closed for being empty before the connection is in it
+func (r *roomRegistry) connect(name string, c *websocket.Conn) (*room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" {
+		name = defaultRoom
+	}
+	if _, ok := r.rooms[name]; !ok && len(r.rooms) >= maxRooms {
+		return nil, errTooManyRooms
+	}
+	rm := r.open(name)
+	if !rm.connect(c) {
+		return nil, errRoomClosed
+	}
+	return rm, nil
+}
+
+// disconnect stops tracking the connection, closing the room once nobody is connected to it
+// rooms with players waiting to reconnect after a restart stay open for them, as do rooms playing back a replay
+func (r *roomRegistry) disconnect(rm *room, c *websocket.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	delete(rm.conns, c)
+	r.closeIfEmpty(rm)
+}
+
+// closeIfEmpty closes the room if nobody is connected to it or waiting to reconnect, with the registry and room locked
+func (r *roomRegistry) closeIfEmpty(rm *room) {
+	if len(rm.conns) > 0 || len(rm.state.reconnecting) > 0 || rm.playback != nil || rm.closed || r.rooms[rm.name] != rm {
+		return
+	}
+	rm.closed = true
+	delete(r.rooms, rm.name)
+	roomPlayers.DeleteLabelValues(rm.name)
+	if rm.recorder != nil {
+		rm.recorder.close()
+		rm.recorder = nil
+	}
+}
+
+func (r *roomRegistry) isClosing() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closing
+}
+
+// lookup returns the named room without opening it
+func (r *roomRegistry) lookup(name string) (*room, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rm, ok := r.rooms[name]
+	return rm, ok
+}
+
+// list returns every open room, in name order
+func (r *roomRegistry) list() []*room {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rooms := []*room{}
+	for _, name := range sortedKeys(r.rooms) {
+		rooms = append(rooms, r.rooms[name])
+	}
+	return rooms
+}
+
+// handle applies the event to the room's game, returning the snapshot for the viewer
+func (rm *room) handle(event gameEvent, viewer string) []byte {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.apply(event)
+	return rm.state.toJSON(viewer)
+}
+
+// handlePlayer applies an event from a player's connection, returning the snapshot for the player it joined as
+// the connection only ever acts as that player, whatever name its events carry, and only joins as a name nobody else is playing
+func (rm *room) handlePlayer(c *websocket.Conn, event gameEvent) []byte {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	name := rm.conns[c]
+	// the admin api's events and anything else clients aren't meant to send are ignored
+	if !eventTypes[event.Type] {
+		return rm.state.toJSON(name)
+	}
+	if event.Type == "kick" {
+		rm.hostKick(name, event)
+		return rm.state.toJSON(name)
+	}
+	if event.Type != "join" {
+		event.Data.Name = name
+		rm.apply(event)
+		return rm.state.toJSON(name)
+	}
+
+	if !rm.canJoinAs(c, event.Data.Name) {
+		droppedEvents.WithLabelValues("name taken").Inc()
+		return rm.state.toJSON(name)
+	}
+	if rm.apply(event) {
+		if _, err := rm.state.getPlayer(event.Data.Name); err == nil {
+			rm.conns[c] = event.Data.Name
+		}
+	}
+	return rm.state.toJSON(rm.conns[c])
+}
+
+// hostKick applies a kick from the named player, closing the connection of whoever it removes
+// otherwise the kicked player's client could just join again
+func (rm *room) hostKick(name string, event gameEvent) {
+	event.Data.Name = name
+	target := event.Data.Target
+	_, err := rm.state.getPlayer(target)
+	wasPlaying := err == nil
+	rm.apply(event)
+	if _, err := rm.state.getPlayer(target); wasPlaying && err != nil {
+		rm.closePlayer(target, hostKickedMessage)
+	}
+}
+
+// closePlayer closes the connections playing as the named player with the room locked, telling their clients why
+// they stop being tracked straight away, so they can't act in the room while they close
+func (rm *room) closePlayer(name, reason string) {
+	for c, player := range rm.conns {
+		if player == name {
+			closeConnection(c, websocket.ClosePolicyViolation, reason)
+			delete(rm.conns, c)
+		}
+	}
+}
+
+// canJoinAs reports whether the connection can join as the named player,
+// which it can't while it is still playing as someone, another connection is playing as them or they are a bot
+func (rm *room) canJoinAs(c *websocket.Conn, name string) bool {
+	if name == "" {
+		return false
+	}
+	if e, err := rm.state.getPlayer(name); err == nil && e.isBot() {
+		return false
+	}
+	if current := rm.conns[c]; current != "" {
+		if _, err := rm.state.getPlayer(current); err == nil {
+			return false
+		}
+	}
+	for other, player := range rm.conns {
+		if other != c && player == name {
+			return false
+		}
+	}
+	return true
+}
+
+// joined returns who the connection is playing as, empty if it hasn't joined
+func (rm *room) joined(c *websocket.Conn) string {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.conns[c]
+}
+
+// apply handles the event with the room locked, reporting whether the room took it
+func (rm *room) apply(event gameEvent) bool {
+	// paused rooms stand still, ignored events aren't recorded so replays don't play them either
+	if rm.state.paused && !pausedEvents[event.Type] && !adminEvents[event.Type] {
+		return false
+	}
+	if rm.closing && event.Type == "join" {
+		droppedEvents.WithLabelValues("shutting down").Inc()
+		return false
+	}
+	now := rm.state.now()
+	if rm.recorder != nil {
+		rm.recorder.record(event, now)
+	}
+	rm.state.handleEventAt(event, now)
+	roomPlayers.WithLabelValues(rm.name).Set(float64(rm.state.playerCount()))
+	return true
+}
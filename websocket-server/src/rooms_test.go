@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoomRegistry(t *testing.T) {
+	opened := 0
+	rooms := newRoomRegistry(func() *gameState {
+		opened++
+		return newGameState()
+	})
+
+	arena := rooms.get("arena")
+	require.Same(t, arena, rooms.get("arena"))
+	require.Same(t, rooms.get(defaultRoom), rooms.get(""))
+	require.NotSame(t, arena, rooms.get(""))
+	require.Equal(t, 2, opened)
+}
+
+func TestRoomsAreSeparate(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+
+	rooms.get("one").handle(gameEvent{Type: "join", Data: eventData{player: testPlayer1FacingRight}}, "player1")
+	snapshot := rooms.get("two").handle(gameEvent{Type: "refresh"}, "")
+
+	decoded := struct {
+		Players []player `json:"players"`
+	}{}
+	require.NoError(t, json.Unmarshal(snapshot, &decoded))
+	require.Empty(t, decoded.Players)
+}
+
+func TestRoomHandlesConcurrentEvents(t *testing.T) {
+	rm := newRoomRegistry(newGameState).get("")
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: testPlayer1FacingRight}}, "player1")
+
+	// run with -race to check events are handled one at a time
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rm.handle(gameEvent{Type: "refresh"}, "player1")
+			rm.handle(gameEvent{Type: "walk", Data: eventData{player: player{Name: "player1", Facing: "down"}}}, "player1")
+		}()
+	}
+	wg.Wait()
+
+	p, err := rm.state.getPlayer("player1")
+	require.NoError(t, err)
+	require.Equal(t, 20, p.Position.Y)
+}
+
+// dialTestRoom connects to the room through the websocket server, like a client would
+func dialTestRoom(t *testing.T, server *httptest.Server, room string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?room=" + room
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// sendTestEvent sends the event and waits for the snapshot in reply
+func sendTestEvent(t *testing.T, c *websocket.Conn, event gameEvent) snapshot {
+	require.NoError(t, c.WriteJSON(event))
+	decoded := snapshot{}
+	require.NoError(t, c.ReadJSON(&decoded))
+	return decoded
+}
+
+func TestConnectionsOnlyActAsTheirPlayer(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	rooms.get("arena").state.setMode("deathmatch")
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	host := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, host, gameEvent{Type: "join", Data: eventData{player: player{X: 100, Y: 100, Name: "host", Facing: "down"}}})
+	victim := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, victim, gameEvent{Type: "join", Data: eventData{player: player{X: 300, Y: 300, Name: "victim", Facing: "down"}}})
+
+	// a connection that never joined can't claim to be the host
+	stranger := dialTestRoom(t, server, "arena")
+	decoded := sendTestEvent(t, stranger, gameEvent{Type: "kick", Data: eventData{player: player{Name: "host"}, Target: "victim"}})
+	require.Len(t, decoded.Players, 2)
+
+	// nor can it join as someone already playing
+	decoded = sendTestEvent(t, stranger, gameEvent{Type: "join", Data: eventData{player: player{Name: "host", Facing: "down"}}})
+	require.Len(t, decoded.Players, 2)
+	decoded = sendTestEvent(t, stranger, gameEvent{Type: "kick", Data: eventData{player: player{Name: "host"}, Target: "victim"}})
+	require.Len(t, decoded.Players, 2)
+
+	// a player's events are theirs, whatever name they carry
+	sendTestEvent(t, victim, gameEvent{Type: "walk", Data: eventData{player: player{Name: "host", Facing: "down"}}})
+	decoded = sendTestEvent(t, victim, gameEvent{Type: "join", Data: eventData{player: player{Name: "other", Facing: "down"}}})
+	require.Len(t, decoded.Players, 2)
+	rm := rooms.get("arena")
+	rm.mu.Lock()
+	require.Equal(t, 100, getTestPlayer(t, rm.state, "host").Y)
+	require.Equal(t, 300+playerWalkDistance, getTestPlayer(t, rm.state, "victim").Y)
+	rm.mu.Unlock()
+
+	// the real host can kick
+	decoded = sendTestEvent(t, host, gameEvent{Type: "kick", Data: eventData{Target: "victim"}})
+	require.Len(t, decoded.Players, 1)
+}
+
+func TestKickedPlayersCantRejoin(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	rooms.get("arena").state.setMode("deathmatch")
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	host := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, host, gameEvent{Type: "join", Data: eventData{player: player{X: 100, Y: 100, Name: "host", Facing: "down"}}})
+	victim := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, victim, gameEvent{Type: "join", Data: eventData{player: player{X: 300, Y: 300, Name: "victim", Facing: "down"}}})
+
+	decoded := sendTestEvent(t, host, gameEvent{Type: "kick", Data: eventData{Target: "victim"}})
+	require.Len(t, decoded.Players, 1)
+
+	// the kicked player's connection is closed, so it can't join again
+	require.NoError(t, victim.SetReadDeadline(time.Now().Add(time.Second)))
+	_, _, err := victim.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+	victim.WriteJSON(gameEvent{Type: "join", Data: eventData{player: player{X: 300, Y: 300, Name: "victim", Facing: "down"}}})
+	decoded = sendTestEvent(t, host, gameEvent{Type: "refresh"})
+	require.Len(t, decoded.Players, 1)
+}
+
+func TestConnectionsCantJoinAsBots(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	rm := rooms.get("arena")
+	rm.state.Settings.Backfill = 2
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	c := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, c, gameEvent{Type: "join", Data: eventData{player: player{Name: "player1", Facing: "down"}}})
+	sendTestEvent(t, c, gameEvent{Type: "refresh"})
+
+	stranger := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, stranger, gameEvent{Type: "join", Data: eventData{player: player{Name: "bot1", Facing: "down"}}})
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	require.Equal(t, 2, rm.state.playerCount())
+	for _, name := range rm.conns {
+		require.NotEqual(t, "bot1", name)
+	}
+}
+
+func TestEmptyRoomsClose(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	rooms.recordDir = t.TempDir()
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	first := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, first, gameEvent{Type: "join", Data: eventData{player: player{Name: "player1", Facing: "down"}}})
+	second := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, second, gameEvent{Type: "join", Data: eventData{player: player{X: 100, Name: "player2", Facing: "down"}}})
+	rm, ok := rooms.lookup("arena")
+	require.True(t, ok)
+
+	// the room stays open while anyone is connected
+	first.Close()
+	require.Eventually(t, func() bool { return connCount(rm) == 1 }, time.Second, 10*time.Millisecond)
+	require.Len(t, rooms.list(), 1)
+
+	// and closes once everyone has gone, opening again empty
+	second.Close()
+	require.Eventually(t, func() bool { return len(rooms.list()) == 0 }, time.Second, 10*time.Millisecond)
+	rm.mu.Lock()
+	require.True(t, rm.closed)
+	require.Nil(t, rm.recorder)
+	rm.mu.Unlock()
+	third := dialTestRoom(t, server, "arena")
+	decoded := sendTestEvent(t, third, gameEvent{Type: "refresh"})
+	require.Empty(t, decoded.Players)
+}
+
+// connCount returns how many connections the room is tracking
+func connCount(rm *room) int {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return len(rm.conns)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// scenarios are scripted games run headlessly against the simulation, for gameplay regression tests
+// a scenario sets up a room, then works through its steps in order, sending events, letting time pass
+// and checking the state is what it should be
+
+// scenarioTickInterval is how often time passing in a scenario refreshes the room, like clients do
+const scenarioTickInterval = 50
+
+type scenario struct {
+	Name     string         `json:"name"`
+	Settings roomSettings   `json:"settings"`
+	Walls    []boundingBox  `json:"walls"`
+	Hazards  []hazard       `json:"hazards"`
+	Seed     int64          `json:"seed"`
+	Players  []player       `json:"players"`
+	NPCs     []scenarioNPC  `json:"npcs"`
+	Items    []scenarioItem `json:"items"`
+	Steps    []scenarioStep `json:"steps"`
+}
+
+type scenarioNPC struct {
+	Name     string `json:"name"`
+	Behavior string `json:"behavior"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+}
+
+type scenarioItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+}
+
+// scenarioStep does one of: handle an event, wait some milliseconds while the room refreshes, or check expectations
+type scenarioStep struct {
+	Event  *gameEvent            `json:"event"`
+	Wait   int64                 `json:"wait"`
+	Expect []scenarioExpectation `json:"expect"`
+}
+
+// scenarioExpectation checks fields of a player, as clients see them, or of the match,
+// or how many entities of a kind there are
+type scenarioExpectation struct {
+	Player   string         `json:"player"`
+	Match    bool           `json:"match"`
+	Entities string         `json:"entities"`
+	Count    int            `json:"count"`
+	Is       map[string]any `json:"is"`
+}
+
+func loadScenario(path string) (scenario, error) {
+	sc := scenario{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sc, fmt.Errorf("read scenario: %w", err)
+	}
+	// fields the scenario doesn't know are an error, so a typo can't quietly leave out a step or expectation
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&sc)
+	if err != nil {
+		return sc, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	if sc.Name == "" {
+		sc.Name = filepath.Base(path)
+	}
+	err = sc.Settings.validateBots()
+	if err != nil {
+		return sc, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	return sc, nil
+}
+
+// newState builds the room the scenario starts in, on a manual clock so time only passes in wait steps
+func (sc scenario) newState() (*gameState, *manualClock, error) {
+	c := &manualClock{}
+	gs := newGameState()
+	gs.clock = c
+	gs.seed(sc.Seed)
+	if sc.Settings.Mode != "" {
+		err := gs.setMode(sc.Settings.Mode)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	gs.Settings = sc.Settings
+	if sc.Walls != nil {
+		gs.Walls = sc.Walls
+	}
+	if sc.Hazards != nil {
+		gs.Hazards = sc.Hazards
+	}
+	for _, p := range sc.Players {
+		gs.addPlayer(p)
+	}
+	for _, npc := range sc.NPCs {
+		if _, ok := behaviors[npc.Behavior]; !ok {
+			return nil, nil, fmt.Errorf("npc %q: unknown behavior %q", npc.Name, npc.Behavior)
+		}
+		gs.addNPC(npc.Name, npc.Behavior, npc.X, npc.Y)
+	}
+	for _, item := range sc.Items {
+		_, err := gs.spawnItem(item.Name, item.Count, item.X, item.Y)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return gs, c, nil
+}
+
+// run plays the scenario, returning every expectation that wasn't met
+func (sc scenario) run() ([]string, error) {
+	gs, c, err := sc.newState()
+	if err != nil {
+		return nil, fmt.Errorf("scenario %q: %w", sc.Name, err)
+	}
+
+	failures := []string{}
+	for i, step := range sc.Steps {
+		if step.Event != nil {
+			gs.handleEvent(*step.Event)
+		}
+		for waited := int64(0); waited < step.Wait; waited += scenarioTickInterval {
+			c.advance(min(scenarioTickInterval, step.Wait-waited))
+			gs.refresh()
+		}
+		for _, expect := range step.Expect {
+			for _, failure := range expect.check(gs) {
+				failures = append(failures, fmt.Sprintf("step %d: %s", i+1, failure))
+			}
+		}
+	}
+	return failures, nil
+}
+
+// check returns how the state differs from the expectation
+func (x scenarioExpectation) check(gs *gameState) []string {
+	var actual any
+	subject := ""
+	switch {
+	case x.Player != "":
+		p, err := gs.getPlayer(x.Player)
+		if err != nil {
+			return []string{fmt.Sprintf("player %q not found", x.Player)}
+		}
+		actual = p.toPlayer()
+		subject = fmt.Sprintf("player %q", x.Player)
+	case x.Match:
+		if gs.Match == nil {
+			return []string{"room has no match"}
+		}
+		actual = gs.Match
+		subject = "match"
+	case x.Entities != "":
+		count := 0
+		for _, e := range gs.entities {
+			if e.Kind == x.Entities {
+				count++
+			}
+		}
+		if count != x.Count {
+			return []string{fmt.Sprintf("%d %s entities, expected %d", count, x.Entities, x.Count)}
+		}
+		return nil
+	default:
+		return []string{"expectation needs a player, match or entities"}
+	}
+
+	fields, err := jsonFields(actual)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	expected, err := jsonFields(x.Is)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	// report fields in name order so failures read the same every run
+	names := []string{}
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failures := []string{}
+	for _, name := range names {
+		if !reflect.DeepEqual(fields[name], expected[name]) {
+			failures = append(failures, fmt.Sprintf("%s %s is %v, expected %v", subject, name, jsonString(fields[name]), jsonString(expected[name])))
+		}
+	}
+	return failures
+}
+
+// jsonFields returns the value's fields the way they are sent as json, so they compare the same as scenario files
+func jsonFields(value any) (map[string]any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("json marshal: %w", err)
+	}
+	fields := map[string]any{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("json unmarshal: %w", err)
+	}
+	return fields, nil
+}
+
+func jsonString(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// runScenarios runs every scenario file matching the pattern, reporting whether they all passed
+func runScenarios(pattern string) (bool, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return false, fmt.Errorf("find scenarios: %w", err)
+	}
+	if len(paths) == 0 {
+		return false, fmt.Errorf("no scenarios match %q", pattern)
+	}
+
+	passed := true
+	for _, path := range paths {
+		sc, err := loadScenario(path)
+		if err != nil {
+			return false, err
+		}
+		failures, err := sc.run()
+		if err != nil {
+			return false, err
+		}
+		if len(failures) == 0 {
+			fmt.Println("PASS", sc.Name)
+			continue
+		}
+		passed = false
+		fmt.Println("FAIL", sc.Name)
+		for _, failure := range failures {
+			fmt.Println("   ", failure)
+		}
+	}
+	return passed, nil
+}
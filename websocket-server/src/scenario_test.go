@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestScenarios runs every scenario in testdata, add a file there to add a case
+func TestScenarios(t *testing.T) {
+	paths, err := filepath.Glob("testdata/scenarios/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		sc, err := loadScenario(path)
+		require.NoError(t, err)
+		t.Run(sc.Name, func(t *testing.T) {
+			failures, err := sc.run()
+			require.NoError(t, err)
+			require.Empty(t, failures)
+		})
+	}
+}
+
+func TestScenarioFailures(t *testing.T) {
+	sc := scenario{
+		Players: []player{{Name: "player1", Facing: "down"}},
+		NPCs:    []scenarioNPC{{Name: "enemy1", Behavior: "grunt", X: 500, Y: 500}},
+		Steps: []scenarioStep{
+			{Wait: 100},
+			{Expect: []scenarioExpectation{
+				{Player: "player1", Is: map[string]any{"health": 50, "facing": "down", "class": "mage"}},
+				{Player: "player2"},
+				{Entities: "npc", Count: 2},
+				{Match: true},
+			}},
+		},
+	}
+
+	failures, err := sc.run()
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`step 2: player "player1" class is "warrior", expected "mage"`,
+		`step 2: player "player1" health is 100, expected 50`,
+		`step 2: player "player2" not found`,
+		`step 2: 1 npc entities, expected 2`,
+		`step 2: room has no match`,
+	}, failures)
+
+	sc.NPCs[0].Behavior = "dance"
+	_, err = sc.run()
+	require.EqualError(t, err, `scenario "": npc "enemy1": unknown behavior "dance"`)
+}
+
+func TestLoadScenarioUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "typo.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"steps": [{"wait": 100, "expcet": [{"player": "player1"}]}]}`), 0o644))
+	_, err := loadScenario(path)
+	require.ErrorContains(t, err, `unknown field "expcet"`)
+}
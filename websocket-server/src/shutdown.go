@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// shutdownMessage is what players are told when the server is going away
+const shutdownMessage = "the server is restarting, reconnect in a moment to carry on where you left off"
+
+// reconnectTimeout is how long restored players are kept waiting for their clients by default
+const reconnectTimeout = 2 * time.Minute
+
+// roomSave is a room written to disk when the server shuts down, enough to carry on the match after a restart
+// players are saved as they are sent to clients, so timers like cooldowns start over
+type roomSave struct {
+	Room string `json:"room"`
+	// Saved is when the room was saved in unix milliseconds, so match timers can pick up where they left off
+	Saved    int64         `json:"saved"`
+	Settings roomSettings  `json:"settings"`
+	Teams    []team        `json:"teams"`
+	Match    *matchState   `json:"match,omitempty"`
+	Players  []playerSave  `json:"players"`
+	Chat     []chatMessage `json:"chat"`
+	Banned   []string      `json:"banned"`
+	Paused   bool          `json:"paused"`
+	Tick     int64         `json:"tick"`
+}
+
+type playerSave struct {
+	player
+	Inventory []inventorySlot `json:"inventory"`
+}
+
+// save captures the room for restoring later, bots aren't saved since backfill brings them back
+func (gs *gameState) save(room string) roomSave {
+	s := roomSave{
+		Room:     room,
+		Saved:    gs.now(),
+		Settings: gs.Settings,
+		Teams:    gs.Teams,
+		Match:    gs.Match,
+		Players:  []playerSave{},
+		Chat:     gs.chatLog,
+		Banned:   sortedKeys(gs.banned),
+		Paused:   gs.paused,
+		Tick:     gs.tick,
+	}
+	for _, e := range gs.entities {
+		if e.Kind == "player" && !e.isBot() {
+			s.Players = append(s.Players, playerSave{player: e.toPlayer(), Inventory: e.Inventory.Slots})
+		}
+	}
+	return s
+}
+
+// restoreRoom puts a saved room back the way it was, with its players waiting for their clients to reconnect
+// it fails before changing anything if the save's mode isn't one the server has
+func (gs *gameState) restoreRoom(s roomSave) error {
+	if s.Settings.Mode != "" {
+		err := gs.setMode(s.Settings.Mode)
+		if err != nil {
+			return err
+		}
+	}
+	gs.Settings = s.Settings
+	gs.Teams = s.Teams
+	for _, p := range s.Players {
+		gs.restorePlayer(p)
+	}
+
+	if s.Match != nil && gs.Match != nil {
+		// the mode sets up what it plays with, like flags and the hill, before the saved scores go back
+		if s.Match.Phase == phaseInProgress {
+			gs.mode.start(gs)
+		}
+		match := *s.Match
+		if match.Scores == nil {
+			match.Scores = map[string]int{}
+		}
+		if match.Ready == nil {
+			match.Ready = map[string]bool{}
+		}
+		// the phase carries on for as long as it had left when the room was saved
+		if match.PhaseEndsAt != 0 {
+			match.PhaseEndsAt += gs.now() - s.Saved
+		}
+		gs.Match = &match
+	}
+
+	gs.chatLog = s.Chat
+	for _, message := range s.Chat {
+		gs.nextChatID = max(gs.nextChatID, message.ID)
+	}
+	for _, name := range s.Banned {
+		gs.banned[name] = true
+	}
+	if s.Paused {
+		gs.pause()
+	}
+	gs.tick = s.Tick
+	return nil
+}
+
+// restorePlayer adds the saved player back where they were, keeping their health, resources, items and score
+func (gs *gameState) restorePlayer(p playerSave) {
+	gs.addPlayer(p.player)
+	e, err := gs.getPlayer(p.Name)
+	if err != nil {
+		log.Println("cannot restore player:", p.Name)
+		return
+	}
+	e.Position.X = p.X
+	e.Position.Y = p.Y
+	e.Actor.Team = p.Team
+	e.Health.Current = p.Health
+	if e.isDead() {
+		e.Actor.respawnAt = gs.now() + respawnDelay
+	}
+	for name, pool := range p.Resources {
+		if current, ok := e.Actor.Resources[name]; ok {
+			current.Current = clamp(pool.Current, 0, current.Max)
+		}
+	}
+	e.Actor.Kills = p.Kills
+	e.Actor.Deaths = p.Deaths
+	if p.Inventory != nil {
+		e.Inventory.Slots = p.Inventory
+	}
+	gs.reconnecting[p.Name] = true
+}
+
+// reconnect hands a restored player back to the client joining with their name, reporting whether there was one
+func (gs *gameState) reconnect(name string) bool {
+	if !gs.reconnecting[name] {
+		return false
+	}
+	delete(gs.reconnecting, name)
+	if _, err := gs.getPlayer(name); err != nil {
+		return false
+	}
+	gs.systemMessage(name, "welcome back")
+	return true
+}
+
+// expireReconnecting removes the restored players whose clients never came back
+func (gs *gameState) expireReconnecting() {
+	for _, name := range sortedKeys(gs.reconnecting) {
+		gs.removePlayer(name)
+		delete(gs.reconnecting, name)
+	}
+}
+
+// expireReconnecting removes the room's restored players who haven't reconnected in time,
+// closing the room if that leaves it empty
+func (r *roomRegistry) expireReconnecting(rm *room) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.state.expireReconnecting()
+	r.closeIfEmpty(rm)
+}
+
+// saveFile is where the named room is saved in dir, room names are escaped since clients pick them
+func saveFile(dir, room string) string {
+	return filepath.Join(dir, url.PathEscape(room)+".room.json")
+}
+
+// saveRoom writes the room to dir, through a temporary file renamed into place
+// that way a server dying mid save leaves the last save whole rather than half written
+func saveRoom(dir string, s roomSave) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("save room %q: %w", s.Room, err)
+	}
+	// the temporary file doesn't end in .room.json, so a half written one is never restored
+	file, err := os.CreateTemp(dir, "save-*.tmp")
+	if err != nil {
+		return fmt.Errorf("save room %q: %w", s.Room, err)
+	}
+	// once it has been renamed there is nothing left to remove
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("save room %q: %w", s.Room, err)
+	}
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("save room %q: %w", s.Room, err)
+	}
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("save room %q: %w", s.Room, err)
+	}
+	err = os.Rename(file.Name(), saveFile(dir, s.Room))
+	if err != nil {
+		return fmt.Errorf("save room %q: %w", s.Room, err)
+	}
+	return nil
+}
+
+// restoreRooms opens every room saved in dir, removing each save once its room is back so it is only restored once
+// saves that can't be restored are logged and left where they are, so one bad save doesn't keep the other rooms away
+func (r *roomRegistry) restoreRooms(dir string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.room.json"))
+	if err != nil {
+		return 0, fmt.Errorf("restore rooms: %w", err)
+	}
+
+	restored := 0
+	for _, path := range paths {
+		err := r.restoreSave(path)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// restoreSave opens the room saved at path, removing the save once the room is back
+// players who haven't reconnected by the registry's reconnect timeout are removed
+func (r *roomRegistry) restoreSave(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", path, err)
+	}
+	s := roomSave{}
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", path, err)
+	}
+
+	rm := r.get(s.Room)
+	rm.mu.Lock()
+	err = rm.state.restoreRoom(s)
+	rm.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("restore room %q: %w", s.Room, err)
+	}
+	time.AfterFunc(r.reconnectTimeout, func() { r.expireReconnecting(rm) })
+	err = os.Remove(path)
+	if err != nil {
+		return fmt.Errorf("restore room %q: %w", s.Room, err)
+	}
+	return nil
+}
+
+// connect tracks a connection to the room so it can be closed on shutdown, reporting false if the room is closed
+func (rm *room) connect(c *websocket.Conn) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.closed {
+		return false
+	}
+	if rm.conns == nil {
+		rm.conns = map[*websocket.Conn]string{}
+	}
+	rm.conns[c] = ""
+	return true
+}
+
+func (rm *room) disconnect(c *websocket.Conn) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	delete(rm.conns, c)
+}
+
+// shutdown closes every room in two steps, first telling everyone and turning away new players,
+// then once grace has passed closing their connections and saving the rooms to dir if there is one
+// players keep playing during the grace period, so their clients see the message in the chat
+func (r *roomRegistry) shutdown(dir string, grace time.Duration) error {
+	r.mu.Lock()
+	r.closing = true
+	r.mu.Unlock()
+
+	rooms := r.list()
+	for _, rm := range rooms {
+		rm.mu.Lock()
+		rm.closing = true
+		rm.state.announce(shutdownMessage)
+		rm.mu.Unlock()
+		rm.broadcast()
+	}
+	time.Sleep(grace)
+
+	errs := []error{}
+	for _, rm := range rooms {
+		rm.mu.Lock()
+		rm.closed = true
+		for c := range rm.conns {
+			closeConnection(c, websocket.CloseServiceRestart, shutdownMessage)
+		}
+		if rm.recorder != nil {
+			rm.recorder.close()
+			rm.recorder = nil
+		}
+		// replays are only played back and empty rooms open again by themselves, neither has anything to carry on with
+		if dir != "" && rm.playback == nil {
+			save := rm.state.save(rm.name)
+			if len(save.Players) > 0 {
+				errs = append(errs, saveRoom(dir, save))
+			}
+		}
+		rm.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// closeConnection tells the client why the connection is closing before closing it
+// close frames can be written while the connection's own goroutine is writing, unlike other messages
+func closeConnection(c *websocket.Conn, code int, reason string) {
+	err := c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	if err != nil {
+		log.Println("close:", err)
+	}
+	c.Close()
+}
+
+// shutdown stops the server taking connections, then closes the rooms, saving them to saveDir if there is one
+func (wss WebsocketServer) shutdown(ctx context.Context) error {
+	// connections already upgraded to websockets aren't waited for, the rooms close those
+	err := wss.server.Shutdown(ctx)
+	if err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return wss.rooms.shutdown(wss.saveDir, wss.shutdownGrace)
+}
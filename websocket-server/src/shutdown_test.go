@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndRestoreRoom(t *testing.T) {
+	dir := t.TempDir()
+	rooms := newRoomRegistry(newGameState)
+	rm := rooms.get("arena")
+	gs := rm.state
+	require.NoError(t, gs.setMode("deathmatch"))
+	gs.addPlayer(player{X: 100, Y: 100, Name: "player1", Facing: "down", Class: "rogue"})
+	gs.addPlayer(player{X: 300, Y: 300, Name: "player2", Facing: "down"})
+	startTestMatch(gs)
+	gs.addBot()
+
+	p1, err := gs.getPlayer("player1")
+	require.NoError(t, err)
+	p1.Position.X = 140
+	p1.Position.Y = 160
+	p1.Health.Current = 42
+	p1.Actor.Resources["stamina"].Current = 17
+	p1.Actor.Kills = 3
+	p1.Inventory.add("coin", 5)
+	gs.Match.Scores["player1"] = 3
+	gs.playerChat("player2", "all", "", "gg")
+	gs.banned["griefer"] = true
+
+	require.NoError(t, rooms.shutdown(dir, 0))
+	_, err = os.Stat(saveFile(dir, "arena"))
+	require.NoError(t, err)
+
+	restoredRooms := newRoomRegistry(newGameState)
+	restored, err := restoredRooms.restoreRooms(dir)
+	require.NoError(t, err)
+	require.Equal(t, 1, restored)
+	rm = restoredRooms.get("arena")
+	gs = rm.state
+
+	// the save is only restored once
+	_, err = os.Stat(saveFile(dir, "arena"))
+	require.True(t, os.IsNotExist(err))
+
+	require.Equal(t, phaseInProgress, gs.Match.Phase)
+	require.Equal(t, 3, gs.Match.Scores["player1"])
+	require.Equal(t, "player1", gs.Match.Host)
+	require.True(t, gs.banned["griefer"])
+	require.Equal(t, "gg", gs.chatFor("player1")[0].Text)
+
+	// bots aren't saved, backfill brings them back if the room wants them
+	require.Len(t, gs.getPlayers(), 2)
+	restoredPlayer := getTestPlayer(t, gs, "player1")
+	require.Equal(t, 140, restoredPlayer.X)
+	require.Equal(t, 160, restoredPlayer.Y)
+	require.Equal(t, 42, restoredPlayer.Health)
+	require.Equal(t, 17, restoredPlayer.Resources["stamina"].Current)
+	require.Equal(t, 3, restoredPlayer.Kills)
+	require.Equal(t, "rogue", restoredPlayer.Class)
+	p1, err = gs.getPlayer("player1")
+	require.NoError(t, err)
+	require.Equal(t, 5, p1.Inventory.count("coin"))
+
+	// joining again takes back the restored player rather than starting over
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{Name: "player1", Facing: "down"}}}, "player1")
+	require.Len(t, gs.getPlayers(), 2)
+	require.Equal(t, 140, getTestPlayer(t, gs, "player1").X)
+	chat := gs.chatFor("player1")
+	require.Equal(t, "welcome back", chat[len(chat)-1].Text)
+}
+
+func TestRestoreSkipsBadSaves(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, saveRoom(dir, roomSave{Room: "arena", Players: []playerSave{{player: player{Name: "player1", Facing: "down"}}}}))
+	require.NoError(t, saveRoom(dir, roomSave{Room: "tag", Settings: roomSettings{Mode: "tag"}}))
+	require.NoError(t, os.WriteFile(saveFile(dir, "broken"), []byte("{"), 0o644))
+
+	// the good save is restored, the bad ones are left for someone to look at
+	rooms := newRoomRegistry(newGameState)
+	restored, err := rooms.restoreRooms(dir)
+	require.NoError(t, err)
+	require.Equal(t, 1, restored)
+	require.Len(t, rooms.get("arena").state.getPlayers(), 1)
+	_, err = os.Stat(saveFile(dir, "broken"))
+	require.NoError(t, err)
+	_, err = os.Stat(saveFile(dir, "tag"))
+	require.NoError(t, err)
+}
+
+func TestShutdown(t *testing.T) {
+	dir := t.TempDir()
+	rooms := newRoomRegistry(newGameState)
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?room=arena"
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.WriteJSON(gameEvent{Type: "join", Data: eventData{player: testPlayer1FacingRight}}))
+	require.NoError(t, c.ReadJSON(&snapshot{}))
+
+	rm := rooms.get("arena")
+	require.NoError(t, rooms.shutdown(dir, 0))
+
+	// the client is told why it was disconnected
+	_, _, err = c.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseServiceRestart))
+
+	// nobody new gets in
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{Name: "player2"}}}, "player2")
+	_, err = rm.state.getPlayer("player2")
+	require.Error(t, err)
+
+	// players saw the shutdown coming in the chat
+	chat := rm.state.chatFor("player1")
+	require.Equal(t, shutdownMessage, chat[len(chat)-1].Text)
+
+	_, err = os.Stat(saveFile(dir, "arena"))
+	require.NoError(t, err)
+}
+
+func TestSaveRoomReplacesTheSave(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, saveRoom(dir, roomSave{Room: "arena", Tick: 1}))
+	require.NoError(t, saveRoom(dir, roomSave{Room: "arena", Tick: 2}))
+
+	// only the save is left, no temporary files
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	rooms := newRoomRegistry(newGameState)
+	restored, err := rooms.restoreRooms(dir)
+	require.NoError(t, err)
+	require.Equal(t, 1, restored)
+	require.Equal(t, int64(2), rooms.get("arena").state.tick)
+}
+
+func TestRestoredPlayersExpire(t *testing.T) {
+	dir := t.TempDir()
+	players := []playerSave{
+		{player: player{Name: "player1", Facing: "down"}},
+		{player: player{X: 100, Name: "player2", Facing: "down"}},
+	}
+	require.NoError(t, saveRoom(dir, roomSave{Room: "arena", Players: players}))
+
+	rooms := newRoomRegistry(newGameState)
+	rooms.reconnectTimeout = 50 * time.Millisecond
+	_, err := rooms.restoreRooms(dir)
+	require.NoError(t, err)
+	rm, ok := rooms.lookup("arena")
+	require.True(t, ok)
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{Name: "player1", Facing: "down"}}}, "player1")
+
+	// whoever hasn't come back by the timeout is removed, and the room closes with nobody connected to it
+	require.Eventually(t, func() bool { return len(rooms.list()) == 0 }, time.Second, 10*time.Millisecond)
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	require.Empty(t, rm.state.reconnecting)
+	require.Len(t, rm.state.getPlayers(), 1)
+	require.Equal(t, "player1", rm.state.getPlayers()[0].Name)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+//go:embed spawners.json
+var defaultSpawnersJSON []byte
+
+// itemSpawner is a spot in the room where an item appears, coming back a while after it is picked up
+type itemSpawner struct {
+	Item  string `json:"item"`
+	Count int    `json:"count"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	// Respawn is how long after the item is picked up another one appears, in milliseconds
+	Respawn int64 `json:"respawn"`
+	// spawned is the item lying at the spawner, 0 once it has been picked up
+	spawned     entityID
+	nextSpawnAt int64
+}
+
+func loadItemSpawners(path string) ([]itemSpawner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read spawners: %w", err)
+	}
+	return parseItemSpawners(data)
+}
+
+// parseItemSpawners reads a spawners file, which is checked against the item kinds so those have to be loaded first
+func parseItemSpawners(data []byte) ([]itemSpawner, error) {
+	parsed := []itemSpawner{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("parse spawners: %w", err)
+	}
+	for i, s := range parsed {
+		err := s.validate()
+		if err != nil {
+			return nil, fmt.Errorf("spawner %d: %w", i+1, err)
+		}
+	}
+	return parsed, nil
+}
+
+func (s itemSpawner) validate() error {
+	kind, ok := itemKinds[s.Item]
+	if !ok {
+		return fmt.Errorf("unknown item %q", s.Item)
+	}
+	if s.Count <= 0 || s.Count > kind.MaxStack {
+		return fmt.Errorf("count must be between 1 and %d", kind.MaxStack)
+	}
+	if s.Respawn < 0 {
+		return fmt.Errorf("respawn cannot be negative")
+	}
+	return nil
+}
+
+// setSpawners gives the room its own copy of the spawners, each spawning its item on the next refresh
+func (gs *gameState) setSpawners(spawners []itemSpawner) {
+	gs.spawners = []itemSpawner{}
+	for _, s := range spawners {
+		s.spawned = 0
+		s.nextSpawnAt = 0
+		gs.spawners = append(gs.spawners, s)
+	}
+}
+
+// spawnerSystem puts each spawner's item back once it has been picked up and its respawn time has passed
+func spawnerSystem(gs *gameState) {
+	now := gs.now()
+	for i := range gs.spawners {
+		s := &gs.spawners[i]
+		if s.spawned != 0 {
+			if _, err := gs.getEntity(s.spawned); err == nil {
+				continue
+			}
+			s.spawned = 0
+			s.nextSpawnAt = now + s.Respawn
+		}
+		if now < s.nextSpawnAt {
+			continue
+		}
+		item, err := gs.spawnItem(s.Item, s.Count, s.X, s.Y)
+		if err != nil {
+			log.Println("spawner:", err)
+			continue
+		}
+		s.spawned = item.ID
+	}
+}
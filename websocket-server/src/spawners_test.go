@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseItemSpawners(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"valid", `[{"item": "coin", "count": 5, "x": 10, "y": 20, "respawn": 1000}]`, ""},
+		{"unknown item", `[{"item": "gem", "count": 1}]`, `spawner 1: unknown item "gem"`},
+		{"no count", `[{"item": "coin"}]`, "spawner 1: count must be between 1 and 99"},
+		{"over a stack", `[{"item": "bow", "count": 2}]`, "spawner 1: count must be between 1 and 1"},
+		{"negative respawn", `[{"item": "coin", "count": 1, "respawn": -1}]`, "spawner 1: respawn cannot be negative"},
+		{"not json", `[`, "parse spawners: unexpected end of JSON input"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseItemSpawners([]byte(test.json))
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, test.err)
+		})
+	}
+
+	_, err := parseItemSpawners(defaultSpawnersJSON)
+	require.NoError(t, err)
+}
+
+func TestItemSpawnerRespawns(t *testing.T) {
+	gs, c := newTestClockGameState()
+	gs.setSpawners([]itemSpawner{{Item: "coin", Count: 3, X: 500, Y: 500, Respawn: 1000}})
+
+	gs.refresh()
+	items := getTestItems(gs)
+	require.Len(t, items, 1)
+	require.Equal(t, 3, items[0].Item.Count)
+
+	// it doesn't pile up while nobody picks it up
+	gs.refresh()
+	require.Len(t, getTestItems(gs), 1)
+
+	// once it is picked up another comes after the respawn time
+	gs.removeEntity(items[0].ID)
+	gs.refresh()
+	require.Empty(t, getTestItems(gs))
+	c.advance(999)
+	gs.refresh()
+	require.Empty(t, getTestItems(gs))
+	c.advance(1)
+	gs.refresh()
+	require.Len(t, getTestItems(gs), 1)
+}
+
+func TestPlayersPickUpSpawnedItems(t *testing.T) {
+	rooms := newRoomRegistry(func() *gameState {
+		gs := newGameState()
+		gs.setSpawners([]itemSpawner{{Item: "spear", Count: 1, X: 100, Y: 100, Respawn: 60000}})
+		return gs
+	})
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	c := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, c, gameEvent{Type: "join", Data: eventData{player: player{X: 90, Y: 90, Name: "player1", Facing: "down"}}})
+	decoded := sendTestEvent(t, c, gameEvent{Type: "refresh"})
+	require.Equal(t, []inventorySlot{{Item: "spear", Count: 1}}, decoded.Inventory)
+
+	// the spear can be equipped like any other
+	decoded = sendTestEvent(t, c, gameEvent{Type: "use", Data: eventData{Item: "spear"}})
+	require.Equal(t, "spear", decoded.Players[0].Weapon)
+}
+
+func TestPlayersCanFindPotions(t *testing.T) {
+	spawners, err := parseItemSpawners(defaultSpawnersJSON)
+	require.NoError(t, err)
+	potions := map[string]itemSpawner{}
+	for _, s := range spawners {
+		if itemKinds[s.Item].Consumable {
+			potions[s.Item] = s
+		}
+	}
+	require.Contains(t, potions, "healthPotion")
+	require.Contains(t, potions, "staminaTonic")
+	require.Contains(t, potions, "regenDraught")
+
+	rooms := newRoomRegistry(func() *gameState {
+		gs := newGameState()
+		gs.setSpawners(spawners)
+		return gs
+	})
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	// a hurt player walks onto a health potion and drinks it
+	potion := potions["healthPotion"]
+	c := dialTestRoom(t, server, "arena")
+	sendTestEvent(t, c, gameEvent{Type: "join", Data: eventData{player: player{X: potion.X - 10, Y: potion.Y - 10, Name: "player1", Facing: "down"}}})
+	rm, ok := rooms.lookup("arena")
+	require.True(t, ok)
+	rm.mu.Lock()
+	p, err := rm.state.getPlayer("player1")
+	require.NoError(t, err)
+	p.Health.Current = 30
+	rm.mu.Unlock()
+
+	decoded := sendTestEvent(t, c, gameEvent{Type: "refresh"})
+	require.Equal(t, []inventorySlot{{Item: "healthPotion", Count: 1}}, decoded.Inventory)
+	health := decoded.Players[0].Health
+	decoded = sendTestEvent(t, c, gameEvent{Type: "use", Data: eventData{Item: "healthPotion"}})
+	require.Equal(t, health+itemKinds["healthPotion"].Health, decoded.Players[0].Health)
+	require.Empty(t, decoded.Inventory)
+}
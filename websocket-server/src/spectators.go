@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// broadcastInterval is how often spectators are sent a snapshot, the same rate players refresh at
+const broadcastInterval = 50 * time.Millisecond
+
+// camera is what a spectator is looking at, either following a player or roaming free
+type camera struct {
+	// Follow is the player the camera stays on, empty for a free camera
+	Follow string `json:"follow,omitempty"`
+	// X and Y are the center of the view
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// spectator watches a room without a player, snapshots are pushed to them rather than asked for
+type spectator struct {
+	camera camera
+	// snapshots holds the latest snapshot until the connection gets round to sending it
+	snapshots chan []byte
+}
+
+// spectate adds a spectator to the room, starting the broadcast if they are the first
+func (rm *room) spectate() *spectator {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	s := &spectator{snapshots: make(chan []byte, 1)}
+	if rm.spectators == nil {
+		rm.spectators = map[*spectator]bool{}
+	}
+	rm.spectators[s] = true
+	if len(rm.spectators) == 1 {
+		rm.stopBroadcast = make(chan struct{})
+		go rm.broadcastLoop(rm.stopBroadcast)
+	}
+	return s
+}
+
+// stopSpectating removes the spectator, stopping the broadcast if nobody else is watching
+func (rm *room) stopSpectating(s *spectator) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if !rm.spectators[s] {
+		return
+	}
+	delete(rm.spectators, s)
+	close(s.snapshots)
+	if len(rm.spectators) == 0 {
+		close(rm.stopBroadcast)
+	}
+}
+
+func (rm *room) broadcastLoop(stop chan struct{}) {
+	ticker := time.NewTicker(broadcastInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			rm.broadcast()
+		}
+	}
+}
+
+// broadcast sends every spectator a snapshot from their camera
+// spectators still sending the last one skip this one rather than holding up the room
+func (rm *room) broadcast() {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	for s := range rm.spectators {
+		select {
+		case s.snapshots <- rm.state.spectatorJSON(&s.camera):
+		default:
+			droppedEvents.WithLabelValues("slow spectator").Inc()
+		}
+	}
+}
+
+// spectatorEvent handles an event from a spectator, which can only change what they are looking at
+// follow events follow the target player, camera events move a free camera to x, y,
+// and speed events change how fast a replay plays
+func (rm *room) spectatorEvent(s *spectator, event gameEvent) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	switch event.Type {
+	case "follow":
+		s.camera.Follow = event.Data.Target
+	case "camera":
+		s.camera = camera{X: event.Data.X, Y: event.Data.Y}
+	case "speed":
+		if rm.playback != nil && event.Data.Speed > 0 {
+			rm.playback.speed = event.Data.Speed
+		}
+	}
+}
+
+// spectatorJSON returns the snapshot sent to spectators, centered on the camera
+// a following camera is moved to the player it follows, so it stays where it last saw them if they leave
+func (gs *gameState) spectatorJSON(c *camera) []byte {
+	if p, err := gs.getPlayer(c.Follow); err == nil {
+		c.X, c.Y = entityCenter(p)
+	}
+
+	s := gs.snapshot("")
+	s.Camera = c
+	start := time.Now()
+	json, err := json.Marshal(s)
+	marshalDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		log.Println("json marshal:", err)
+	}
+	return json
+}
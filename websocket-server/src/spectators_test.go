@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeSpectatorSnapshot waits for the spectator's next snapshot
+func decodeSpectatorSnapshot(t *testing.T, s *spectator) snapshot {
+	decoded := snapshot{}
+	require.NoError(t, json.Unmarshal(<-s.snapshots, &decoded))
+	return decoded
+}
+
+func TestSpectatorCamera(t *testing.T) {
+	rm := newRoomRegistry(newGameState).get("")
+	rm.handle(gameEvent{Type: "join", Data: eventData{player: player{X: 100, Y: 200, Name: "player1", Facing: "down"}}}, "player1")
+
+	s := rm.spectate()
+	defer rm.stopSpectating(s)
+
+	// spectators don't get a player
+	rm.broadcast()
+	decoded := decodeSpectatorSnapshot(t, s)
+	require.Len(t, decoded.Players, 1)
+	require.Equal(t, camera{}, *decoded.Camera)
+
+	rm.spectatorEvent(s, gameEvent{Type: "follow", Data: eventData{Target: "player1"}})
+	rm.broadcast()
+	decoded = decodeSpectatorSnapshot(t, s)
+	require.Equal(t, camera{Follow: "player1", X: 100 + playerSpriteWidth/2, Y: 200 + playerSpriteHeight/2}, *decoded.Camera)
+
+	// the camera stays where it last saw a player who leaves
+	rm.handle(gameEvent{Type: "leave", Data: eventData{player: player{Name: "player1"}}}, "player1")
+	rm.broadcast()
+	decoded = decodeSpectatorSnapshot(t, s)
+	require.Equal(t, camera{Follow: "player1", X: 100 + playerSpriteWidth/2, Y: 200 + playerSpriteHeight/2}, *decoded.Camera)
+
+	rm.spectatorEvent(s, gameEvent{Type: "camera", Data: eventData{player: player{X: 5, Y: 6}}})
+	rm.broadcast()
+	decoded = decodeSpectatorSnapshot(t, s)
+	require.Equal(t, camera{X: 5, Y: 6}, *decoded.Camera)
+}
+
+func TestSpectatorsCannotPlay(t *testing.T) {
+	rooms := newRoomRegistry(newGameState)
+	wss := WebsocketServer{cors: "*", rooms: rooms}
+	server := httptest.NewServer(http.HandlerFunc(wss.state))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?room=arena"
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.WriteJSON(gameEvent{Type: "spectate"}))
+	require.NoError(t, c.WriteJSON(gameEvent{Type: "join", Data: eventData{player: testPlayer1FacingRight}}))
+
+	// snapshots arrive without asking for them, and the join was ignored
+	for i := 0; i < 3; i++ {
+		decoded := snapshot{}
+		require.NoError(t, c.ReadJSON(&decoded))
+		require.Empty(t, decoded.Players)
+		require.NotNil(t, decoded.Camera)
+	}
+	rm := rooms.get("arena")
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	require.Zero(t, rm.state.playerCount())
+}
@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"math"
+	"math/rand"
 	"time"
+
+	"toast-websocket-server/src/pathfinding"
 )
 
 const playerSpriteHeight = 48
@@ -15,53 +19,128 @@ const playerHitboxWidth = 24
 
 const playerDodgeDistance = 24
 
+// playerWalkDistance is how far actors walk each step, before any effects slow them down
+const playerWalkDistance = 2
+
 type gameState struct {
-	Players []player `json:"players"`
+	Walls        []boundingBox `json:"walls"`
+	Hazards      []hazard      `json:"hazards"`
+	Bounds       boundingBox   `json:"bounds"`
+	Teams        []team        `json:"teams"`
+	Settings     roomSettings  `json:"settings"`
+	Match        *matchState   `json:"match,omitempty"`
+	entities     []*entity
+	nextEntityID entityID
+	navGrid      *pathfinding.Grid
+	regen        regenRules
+	tuning       tuning
+	mode         gameMode
+	clock        clock
+	// tick counts the refreshes the room has run
+	tick       int64
+	rng        *rand.Rand
+	rngSeed    int64
+	chatLog    []chatMessage
+	nextChatID int
+	// enemies is how many npc enemies were spawned when the room was set up
+	enemies int
+	// spawners put items in the room for players to pick up
+	spawners []itemSpawner
+	// paused rooms don't refresh or take input, while an admin has them paused
+	// pausedAt is the room's time when it was paused, pausedFor is how long it has spent paused altogether
+	paused    bool
+	pausedAt  int64
+	pausedFor int64
+	// handling is set while an event is being handled at handledAt, the room's time stays there until it is done
+	handling  bool
+	handledAt int64
+	// banned are the names that can't join the room
+	banned map[string]bool
+	// reconnecting are the players restored after a restart, waiting for their clients to join again
+	reconnecting map[string]bool
 }
 
+// player is how players are sent over the websocket,
+// both in join events and in snapshots of the game state
 type player struct {
 	X           int    `json:"x"`
 	Y           int    `json:"y"`
 	Name        string `json:"name"`
+	Class       string `json:"class"`
+	Team        string `json:"team"`
+	TeamColor   string `json:"teamColor"`
 	Health      int    `json:"health"`
 	Stamina     int    `json:"stamina"`
 	Facing      string `json:"facing"`
 	IsAttacking bool   `json:"isAttacking"`
 	IsWalking   bool   `json:"isWalking"`
 	IsDodging   bool   `json:"isDodging"`
-	lastAttack  int64
-	lastWalk    int64
-	lastDodge   int64
+	IsUsing     bool   `json:"isUsing"`
 	Skin        string `json:"skin"`
-}
-
-type playerBoundingBox struct {
-	hitbox boundingBox `json:"hitbox"`
-	sprite boundingBox `json:"sprite"`
+	Weapon      string `json:"weapon"`
+	// Effects are the status effects on the player, for showing as icons
+	Effects []statusEffect `json:"effects"`
+	// Resources are all of the player's pools, stamina included
+	Resources map[string]resourcePool `json:"resources"`
+	Kills     int                     `json:"kills"`
+	Deaths    int                     `json:"deaths"`
+	IsBot     bool                    `json:"isBot"`
 }
 
 type boundingBox struct {
-	x      int `json:"x"`
-	y      int `json:"y"`
-	width  int `json:"width"`
-	height int `json:"height"`
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
 }
 
 type gameEvent struct {
-	Type string `json:"type"`
-	Data player `json:"data"`
+	Type string    `json:"type"`
+	Data eventData `json:"data"`
 }
 
-type attackHitbox struct {
-	topLeftCornerX     int
-	topLeftCornerY     int
-	bottomRightCornerX int
-	bottomRightCornerY int
+// eventData is a player, plus the item being used or dropped, the chat message being sent,
+// what they are doing in the lobby, or how a spectator is watching
+type eventData struct {
+	player
+	Item     string         `json:"item"`
+	Count    int            `json:"count"`
+	Channel  string         `json:"channel"`
+	Message  string         `json:"message"`
+	Ready    bool           `json:"ready"`
+	Target   string         `json:"target"`
+	Settings settingsChange `json:"settings"`
+	// Speed is how fast a spectator wants a replay played
+	Speed float64 `json:"speed"`
 }
 
-func (gs *gameState) toJSON() []byte {
-	// convert the gameState to a json byte slice
-	json, err := json.Marshal(gs)
+// snapshot is the game state as sent to clients
+// players are flattened for the frontend, every entity is also included as is
+// inventory and chat are only what the player the snapshot is for can see
+// camera is only sent to spectators
+type snapshot struct {
+	Players   []player        `json:"players"`
+	Entities  []*entity       `json:"entities"`
+	Walls     []boundingBox   `json:"walls"`
+	Hazards   []hazard        `json:"hazards"`
+	Bounds    boundingBox     `json:"bounds"`
+	Teams     []team          `json:"teams"`
+	Settings  roomSettings    `json:"settings"`
+	Match     *matchState     `json:"match,omitempty"`
+	Inventory []inventorySlot `json:"inventory,omitempty"`
+	Chat      []chatMessage   `json:"chat"`
+	Camera    *camera         `json:"camera,omitempty"`
+	Tick      int64           `json:"tick"`
+	Paused    bool            `json:"paused"`
+}
+
+// toJSON returns the snapshot sent to the named player
+func (gs *gameState) toJSON(viewer string) []byte {
+	// convert the snapshot to a json byte slice
+	s := gs.snapshot(viewer)
+	start := time.Now()
+	json, err := json.Marshal(s)
+	marshalDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
 		log.Println("json marshal:", err)
 	}
@@ -69,6 +148,26 @@ func (gs *gameState) toJSON() []byte {
 	return json
 }
 
+func (gs *gameState) snapshot(viewer string) snapshot {
+	s := snapshot{
+		Players:  gs.getPlayers(),
+		Entities: gs.entities,
+		Walls:    gs.Walls,
+		Hazards:  gs.Hazards,
+		Bounds:   gs.Bounds,
+		Teams:    gs.Teams,
+		Settings: gs.Settings,
+		Match:    gs.Match,
+		Tick:     gs.tick,
+		Paused:   gs.paused,
+		Chat:     gs.chatFor(viewer),
+	}
+	if p, err := gs.getPlayer(viewer); err == nil {
+		s.Inventory = p.Inventory.Slots
+	}
+	return s
+}
+
 func (gs *gameState) handleEvent(event gameEvent) {
 	if event.Type == "refresh" {
 		// handle refresh event
@@ -82,69 +181,134 @@ func (gs *gameState) handleEvent(event gameEvent) {
 		// handle walk event
 		gs.playerWalk(event.Data.Name, event.Data.Facing)
 	}
+	if event.Type == "moveTo" {
+		// handle move to event
+		// data should be the name of the player and where they want to go
+		gs.playerMoveTo(event.Data.Name, event.Data.X, event.Data.Y)
+	}
 	if event.Type == "dodge" {
 		// handle dodge event
 		gs.playerDodge(event.Data.Name)
 	}
+	if event.Type == "ability" {
+		// handle ability event
+		// data should be the name of the player using their class ability
+		gs.playerAbility(event.Data.Name)
+	}
+	if event.Type == "use" {
+		// handle use event
+		// data should be the name of the player and the item to use
+		gs.playerUseItem(event.Data.Name, event.Data.Item)
+	}
+	if event.Type == "drop" {
+		// handle drop event
+		// data should be the name of the player, the item and how many to drop
+		gs.playerDropItem(event.Data.Name, event.Data.Item, event.Data.Count)
+	}
+	if event.Type == "chat" {
+		// handle chat event
+		// data should be the name of the player, the channel and the message, and who to whisper to
+		gs.playerChat(event.Data.Name, event.Data.Channel, event.Data.Target, event.Data.Message)
+	}
+	if event.Type == "mute" {
+		// handle mute event
+		// data should be the name of the player and who to mute
+		gs.playerMute(event.Data.Name, event.Data.Target, true)
+	}
+	if event.Type == "unmute" {
+		// handle unmute event
+		// data should be the name of the player and who to unmute
+		gs.playerMute(event.Data.Name, event.Data.Target, false)
+	}
+	if event.Type == "ready" {
+		// handle ready event
+		// data should be the name of the player and whether they are ready
+		gs.playerReady(event.Data.Name, event.Data.Ready)
+	}
+	if event.Type == "choose" {
+		// handle choose event
+		// data should be the name of the player and the class, skin or team they want
+		gs.playerChoose(event.Data.player)
+	}
+	if event.Type == "start" {
+		// handle start event
+		// data should be the name of the host
+		gs.hostStart(event.Data.Name)
+	}
+	if event.Type == "kick" {
+		// handle kick event
+		// data should be the name of the host and the player to kick
+		gs.hostKick(event.Data.Name, event.Data.Target)
+	}
+	if event.Type == "settings" {
+		// handle settings event
+		// data should be the name of the host and the new settings
+		gs.hostSettings(event.Data.Name, event.Data.Settings)
+	}
 	if event.Type == "join" {
 		// handle join event
 		// data should be the name of the player joining
-		gs.addPlayer(event.Data)
+		gs.addPlayer(event.Data.player)
 	}
 	if event.Type == "leave" {
 		// handle leave event
 		// data should be the name of the player leaving
 		gs.removePlayer(event.Data.Name)
 	}
-}
-
-func (gs *gameState) refresh() {
-	// refresh the game state
-	for i, p := range gs.Players {
-		if p.IsAttacking && time.Now().UnixMilli()-p.lastAttack > 400 {
-			p.IsAttacking = false
-			gs.Players[i] = p
-		}
-		if p.IsWalking && time.Now().UnixMilli()-p.lastWalk > 250 {
-			p.IsWalking = false
-			gs.Players[i] = p
-		}
-		if p.IsDodging && time.Now().UnixMilli()-p.lastDodge > 300 {
-			p.IsDodging = false
-			gs.Players[i] = p
-		}
-
-		// restore stamina gradually if below 100
-		if p.Stamina < 100 {
-			p.Stamina += 1
-			gs.Players[i] = p
-		}
+	if event.Type == "adminKick" {
+		// handle admin kick event
+		// data should be the player to kick as the target
+		gs.removePlayer(event.Data.Target)
 	}
-}
-
-func (gs *gameState) consumePlayerStamina(p player, staminaAmount int) {
-	// consume stamina from the player
-	p.Stamina -= staminaAmount
-	if p.Stamina < 0 {
-		p.Stamina = 0
+	if event.Type == "adminBan" {
+		// handle admin ban event
+		// data should be the player to ban as the target
+		gs.adminBan(event.Data.Target)
+	}
+	if event.Type == "adminTeleport" {
+		// handle admin teleport event
+		// data should be the player to teleport as the target and where to
+		gs.adminTeleport(event.Data.Target, event.Data.X, event.Data.Y)
+	}
+	if event.Type == "adminHeal" {
+		// handle admin heal event
+		// data should be the player to heal as the target and how much as the count
+		gs.adminHeal(event.Data.Target, event.Data.Count)
+	}
+	if event.Type == "pause" {
+		gs.pause()
+	}
+	if event.Type == "resume" {
+		gs.resume()
+	}
+	if event.Type == "announce" {
+		// handle announce event
+		// data should be the message from the server
+		gs.announce(event.Data.Message)
 	}
-	gs.updatePlayer(p)
 }
 
-func (gs *gameState) playerHasStamina(p player, staminaAmount int) bool {
-	// check if the player has enough stamina
-	return p.Stamina >= staminaAmount
+func (gs *gameState) refresh() {
+	// bots send their input first, the way clients do between refreshes
+	gs.playBots()
+
+	// refresh the game state by running every system
+	start := time.Now()
+	gs.tick++
+	for _, system := range systems {
+		system(gs)
+	}
+	tickDuration.Observe(time.Since(start).Seconds())
 }
 
 func (gs *gameState) removePlayer(name string) {
 	// remove the player with the given name
-	newPlayers := []player{}
-	for _, p := range gs.Players {
-		if p.Name != name {
-			newPlayers = append(newPlayers, p)
-		}
+	p, err := gs.getPlayer(name)
+	if err != nil {
+		return
 	}
-	gs.Players = newPlayers
+	gs.leaveLobby(name)
+	gs.removeEntity(p.ID)
 }
 
 func (gs *gameState) playerDodge(name string) {
@@ -153,86 +317,55 @@ func (gs *gameState) playerDodge(name string) {
 		log.Println("cannot find dodging player")
 		return
 	}
+	gs.dodge(p)
+}
+
+func (gs *gameState) dodge(e *entity) {
+	if e.isBusy() {
+		return
+	}
 
-	// check if the player has enough stamina to dodge
-	if !gs.playerHasStamina(p, 30) {
+	// check if the actor can afford to dodge
+	if !gs.canAfford(e, gs.tuning.DodgeCost) {
 		return
 	}
 
-	p.IsDodging = true
-	p.lastDodge = time.Now().UnixMilli()
-	gs.updatePlayer(p)
+	e.Actor.IsDodging = true
+	e.Actor.lastDodge = gs.now()
 
-	// consume stamina
-	gs.consumePlayerStamina(p, 30)
+	// pay for the dodge
+	gs.spend(e, gs.tuning.DodgeCost)
 
-	// dodge roll should advance player 10 units in the direction they are facing
-	switch p.Facing {
+	// dodge roll should advance the actor in the direction they are facing
+	x := e.Position.X
+	y := e.Position.Y
+	switch e.Actor.Facing {
 	case "up":
-		gs.movePlayer(name, p.X, p.Y-playerDodgeDistance)
+		gs.moveEntity(e, x, y-gs.tuning.DodgeDistance)
 	case "down":
-		gs.movePlayer(name, p.X, p.Y+playerDodgeDistance)
+		gs.moveEntity(e, x, y+gs.tuning.DodgeDistance)
 	case "left":
-		gs.movePlayer(name, p.X-playerDodgeDistance, p.Y)
+		gs.moveEntity(e, x-gs.tuning.DodgeDistance, y)
 	case "right":
-		gs.movePlayer(name, p.X+playerDodgeDistance, p.Y)
+		gs.moveEntity(e, x+gs.tuning.DodgeDistance, y)
 	}
 }
 
-func (gs *gameState) playerAttackHit(name string) (bool, string) {
-	// check if player is facing another player within 10 units of them
-	// if so, return true and the name of the player they hit
-	// otherwise, return false and an empty string
+func (gs *gameState) playerAttackHit(name string) []*entity {
+	// find everything in the area covered by the attacking player's weapon
+	// returns the entities hit, nearest first, up to the weapon's max targets
 
-	player, err := gs.getPlayer(name)
+	p, err := gs.getPlayer(name)
 	if err != nil {
 		log.Println("cannot find attacking player:", err)
-		return false, ""
-	}
-
-	playerFacing := player.Facing
-	playerX := player.X
-	playerY := player.Y
-
-	for _, p := range gs.Players {
-		if p.Name == name {
-			continue
-		}
-		if p.IsDodging {
-			continue
-		}
-
-		hitbox := attackHitbox{}
-		switch playerFacing {
-		case "up":
-			hitbox.topLeftCornerX = playerX
-			hitbox.topLeftCornerY = playerY - 10
-			hitbox.bottomRightCornerX = playerX + playerSpriteWidth
-			hitbox.bottomRightCornerY = playerY
-		case "down":
-			hitbox.topLeftCornerX = playerX
-			hitbox.topLeftCornerY = playerY + playerSpriteHeight
-			hitbox.bottomRightCornerX = playerX + playerSpriteWidth
-			hitbox.bottomRightCornerY = playerY + playerSpriteHeight + 10
-		case "left":
-			hitbox.topLeftCornerX = playerX - 10
-			hitbox.topLeftCornerY = playerY
-			hitbox.bottomRightCornerX = playerX
-			hitbox.bottomRightCornerY = playerY + playerSpriteHeight
-		case "right":
-			hitbox.topLeftCornerX = playerX + playerSpriteWidth
-			hitbox.topLeftCornerY = playerY
-			hitbox.bottomRightCornerX = playerX + playerSpriteWidth + 10
-			hitbox.bottomRightCornerY = playerY + playerSpriteHeight
-		}
-
-		if p.X+playerSpriteWidth >= hitbox.topLeftCornerX && p.X <= hitbox.bottomRightCornerX && p.Y+playerSpriteHeight >= hitbox.topLeftCornerY && p.Y <= hitbox.bottomRightCornerY {
-			return true, p.Name
-		}
-
+		return []*entity{}
 	}
+	return gs.attackTargets(p)
+}
 
-	return false, ""
+func (gs *gameState) attackTargets(e *entity) []*entity {
+	w := gs.weapon(e.Actor.Weapon)
+	return gs.entitiesHitByShape(e, w.hitShape(e), w.MaxTargets)
 }
 
 func (gs *gameState) playerAttack(name string) {
@@ -241,30 +374,43 @@ func (gs *gameState) playerAttack(name string) {
 		log.Println("cannot find attacking player")
 		return
 	}
+	gs.attack(p)
+}
+
+func (gs *gameState) attack(e *entity) {
+	// can't attack again until the current swing is finished
+	if e.Actor.IsAttacking || e.isBusy() {
+		return
+	}
 
-	// check if the player has enough stamina to attack
-	if !gs.playerHasStamina(p, 25) {
+	// check if the actor can afford to attack
+	w := gs.weapon(e.Actor.Weapon)
+	if !gs.canAfford(e, w.Cost) {
 		return
 	}
 
-	// set the attacking player to be attacking
-	p.IsAttacking = true
-	p.lastAttack = time.Now().UnixMilli()
-	gs.updatePlayer(p)
+	// set the actor to be attacking
+	e.Actor.IsAttacking = true
+	e.Actor.lastAttack = gs.now()
 
-	// consume stamina
-	gs.consumePlayerStamina(p, 25)
+	// pay for the attack
+	gs.spend(e, w.Cost)
 
-	// apply damage if another player was hit
-	hit, hitName := gs.playerAttackHit(name)
-	if hit {
-		hitPlayer, err := gs.getPlayer(hitName)
-		if err != nil {
-			log.Println("cannot find player to attack")
-			return
+	// ranged weapons fire a projectile instead of hitting straight away
+	if w.Projectile != "" {
+		p := gs.spawnProjectile(e, w.Projectile, w.Damage)
+		if p != nil {
+			p.Projectile.effects = w.Effects
+		}
+		return
+	}
+
+	// apply damage and effects to everything that was hit
+	for _, target := range gs.attackTargets(e) {
+		gs.hit(e, target, w.Damage)
+		for _, effect := range w.Effects {
+			gs.applyEffect(target, effect)
 		}
-		hitPlayer.Health -= 10
-		gs.updatePlayer(hitPlayer)
 	}
 }
 
@@ -274,110 +420,204 @@ func (gs *gameState) playerWalk(name, direction string) {
 		log.Println("cannot find walking player")
 		return
 	}
-	p.Facing = direction
 
-	p.IsWalking = true
-	p.lastWalk = time.Now().UnixMilli()
-	gs.updatePlayer(p)
+	// walking by hand cancels any move to
+	p.Actor.path = nil
+	gs.walk(p, direction)
+}
+
+func (gs *gameState) walk(e *entity, direction string) {
+	if e.isBusy() {
+		return
+	}
+
+	e.Actor.Facing = direction
+	e.Actor.IsWalking = true
+	e.Actor.lastWalk = gs.now()
 
+	distance := gs.walkDistance(e)
+	x := e.Position.X
+	y := e.Position.Y
 	switch direction {
 	case "up":
-		gs.movePlayer(name, p.X, p.Y-2)
+		gs.moveEntity(e, x, y-distance)
 	case "down":
-		gs.movePlayer(name, p.X, p.Y+2)
+		gs.moveEntity(e, x, y+distance)
 	case "left":
-		gs.movePlayer(name, p.X-2, p.Y)
+		gs.moveEntity(e, x-distance, y)
 	case "right":
-		gs.movePlayer(name, p.X+2, p.Y)
+		gs.moveEntity(e, x+distance, y)
 	}
 }
 
-func (gs *gameState) movePlayer(name string, x, y int) {
-	p, err := gs.getPlayer(name)
+// walkDistance is how far the actor walks each step, after their speed and effects
+func (gs *gameState) walkDistance(e *entity) int {
+	return int(math.Round(float64(gs.tuning.WalkDistance) * e.Actor.speed * e.speedMultiplier()))
+}
 
-	if err != nil {
-		log.Println("cannot find moving player")
+func (gs *gameState) moveEntity(e *entity, x, y int) {
+	if gs.blocked(e, x, y) {
 		return
 	}
-	// don't allow player to collide with other players' bounding box (taking into account sprite dimensions)
-	for _, player := range gs.Players {
-		otherPlayerBoundingBox := getPlayerBoundingBox(player)
-		otherPlayerHitboxX := otherPlayerBoundingBox.hitbox.x
-		otherPlayerHitboxY := otherPlayerBoundingBox.hitbox.y
 
-		if player.Name == name {
-			continue
-		}
-		if x+playerHitboxWidth >= otherPlayerHitboxX && x <= otherPlayerHitboxX+playerHitboxWidth && y+playerHitboxHeight >= otherPlayerHitboxY && y <= otherPlayerHitboxY+playerHitboxHeight {
-			return
+	// no collision, so move entity
+	e.Position.X = x
+	e.Position.Y = y
+}
+
+// blocked reports whether the entity would collide with anything at x, y
+func (gs *gameState) blocked(e *entity, x, y int) bool {
+	if e.Hitbox == nil {
+		return false
+	}
+	newHitbox := e.hitboxAt(x, y)
+
+	// don't allow solid entities to collide with each other's hitbox
+	if e.Hitbox.Solid {
+		for _, other := range gs.entities {
+			if other.ID == e.ID || other.Hitbox == nil || !other.Hitbox.Solid {
+				continue
+			}
+			if boxesOverlap(newHitbox, other.hitboxBox()) {
+				return true
+			}
 		}
 	}
 
-	// no collision, so move player
-	p.X = x
-	p.Y = y
-	gs.updatePlayer(p)
+	// don't allow entities to walk into walls
+	return gs.collidesWithWall(newHitbox)
 }
 
-func (gs *gameState) updatePlayer(p player) {
-	for i, player := range gs.Players {
-		if player.Name == p.Name {
-			gs.Players[i] = p
-			return
-		}
+// newPlayerEntity builds the entity for a player joining the game
+// health, resources and speed come from their class rather than the client
+func newPlayerEntity(p player, t tuning) *entity {
+	e := newActorEntity("player", p, t)
+	e.applyClass(p.Class)
+	e.Inventory = &inventoryComponent{Slots: []inventorySlot{}}
+	return e
+}
+
+// newActorEntity builds an entity with a player's body, that can walk, attack and dodge
+func newActorEntity(kind string, p player, t tuning) *entity {
+	stamina := newResourcePool(defaultStamina)
+	stamina.Current = clamp(p.Stamina, 0, stamina.Max)
+	e := &entity{
+		Kind:     kind,
+		Position: &positionComponent{X: p.X, Y: p.Y},
+		Health:   &healthComponent{Current: p.Health, Max: p.Health},
+		Hitbox:   &hitboxComponent{Solid: true},
+		Sprite:   &spriteComponent{Skin: p.Skin},
+		Actor: &actorComponent{
+			Name:        p.Name,
+			Facing:      p.Facing,
+			Resources:   map[string]*resourcePool{"stamina": stamina},
+			Weapon:      selectWeapon(p.Weapon),
+			IsAttacking: p.IsAttacking,
+			IsWalking:   p.IsWalking,
+			IsDodging:   p.IsDodging,
+			speed:       1,
+			spawn:       positionComponent{X: p.X, Y: p.Y},
+			muted:       map[string]bool{},
+		},
 	}
+	t.fitBody(e)
+	return e
 }
 
+// toPlayer flattens a player entity for sending to clients
+func (e *entity) toPlayer() player {
+	resources := map[string]resourcePool{}
+	for name, pool := range e.Actor.Resources {
+		resources[name] = resourcePool{resourcePoolKind: pool.resourcePoolKind, Current: pool.Current}
+	}
+	return player{
+		X:           e.Position.X,
+		Y:           e.Position.Y,
+		Name:        e.Actor.Name,
+		Class:       e.Actor.Class,
+		Team:        e.Actor.Team,
+		Health:      e.Health.Current,
+		Stamina:     e.resource("stamina"),
+		Facing:      e.Actor.Facing,
+		IsAttacking: e.Actor.IsAttacking,
+		IsWalking:   e.Actor.IsWalking,
+		IsDodging:   e.Actor.IsDodging,
+		IsUsing:     e.Actor.IsUsing,
+		Skin:        e.Sprite.Skin,
+		Weapon:      e.Actor.Weapon,
+		Effects:     e.Actor.Effects,
+		Resources:   resources,
+		Kills:       e.Actor.Kills,
+		Deaths:      e.Actor.Deaths,
+		IsBot:       e.Actor.IsBot,
+	}
+}
+
+// addPlayer joins the player, putting them on a team and in its spawn area if the room has teams
 func (gs *gameState) addPlayer(p player) {
-	gs.Players = append(gs.Players, p)
+	if gs.banned[p.Name] {
+		log.Println("banned player tried to join:", p.Name)
+		return
+	}
+	if gs.reconnect(p.Name) {
+		return
+	}
+	// a name is one player, so joining as someone already in the room carries on as them rather than adding another
+	// the room only lets a connection do that once nobody else is playing as them, and never for bots
+	if existing, err := gs.getPlayer(p.Name); err == nil {
+		if existing.isBot() {
+			log.Println("player tried to join as a bot:", p.Name)
+		}
+		return
+	}
+	e := newPlayerEntity(p, gs.tuning)
+	if t, ok := gs.selectTeam(p.Team); ok {
+		e.Actor.Team = t.Name
+		gs.spawnInArea(e, t.Spawn)
+	}
+	gs.addEntity(e)
+	gs.joinLobby(p.Name)
 }
 
-func (gs *gameState) getPlayer(name string) (player, error) {
+func (gs *gameState) getPlayer(name string) (*entity, error) {
 	// get player with the given name
-	for _, p := range gs.Players {
-		if p.Name == name {
-			return p, nil
+	for _, e := range gs.entities {
+		if e.Kind == "player" && e.Actor.Name == name {
+			return e, nil
 		}
 	}
-	return player{}, errors.New("player not found")
+	return nil, errors.New("player not found")
 }
 
 func (gs *gameState) getPlayers() []player {
 	players := []player{}
-	for _, p := range gs.Players {
-		players = append(players, p)
+	for _, e := range gs.entities {
+		if e.Kind == "player" {
+			p := e.toPlayer()
+			if t, ok := gs.getTeam(p.Team); ok {
+				p.TeamColor = t.Color
+			}
+			players = append(players, p)
+		}
 	}
 	return players
 }
 
-func newGameState() gameState {
-	return gameState{
-		Players: []player{},
-	}
-}
-
-func getPlayerBoundingBox(p player) playerBoundingBox {
-	// figure out hitbox coordinates based on sprite position and dimensions
-	// hitbox is a rectangle with the same center as the sprite
-	const hitboxWidth = playerHitboxWidth
-	const hitboxHeight = playerHitboxHeight
-	const hitboxOffsetX = (playerSpriteWidth - hitboxWidth) / 2
-	const hitboxOffsetY = (playerSpriteHeight - hitboxHeight) / 2
-	hitboxTopLeftX := p.X + hitboxOffsetX
-	hitboxTopLeftY := p.Y + hitboxOffsetY
-
-	return playerBoundingBox{
-		hitbox: boundingBox{
-			x:      hitboxTopLeftX,
-			y:      hitboxTopLeftY,
-			width:  hitboxWidth,
-			height: hitboxHeight,
-		},
-		sprite: boundingBox{
-			x:      p.X,
-			y:      p.Y,
-			width:  playerSpriteWidth,
-			height: playerSpriteHeight,
-		},
+// newGameState returns an empty room on the system clock, with randomness seeded from the time
+func newGameState() *gameState {
+	gs := &gameState{
+		Walls:    []boundingBox{},
+		Hazards:  []hazard{},
+		Teams:    []team{},
+		Bounds:   defaultBounds,
+		entities: []*entity{},
+		regen:    defaultRegenRules,
+		tuning:   defaultTuning,
+		clock:    systemClock{},
+		banned:   map[string]bool{},
+
+		reconnecting: map[string]bool{},
 	}
+	gs.seed(time.Now().UnixNano())
+	return gs
 }
@@ -11,6 +11,7 @@ var testPlayer1FacingRight = player{
 	Y:           0,
 	Name:        "player1",
 	Health:      100,
+	Stamina:     100,
 	Facing:      "right",
 	IsAttacking: false,
 	Skin:        "skin1",
@@ -43,17 +44,40 @@ var testPlayer1FacingDown = player{
 	Skin:        "skin1",
 }
 
+// newTestGameState creates a gameState with the given players joined, in order
+func newTestGameState(players ...player) *gameState {
+	gs := newGameState()
+	for _, p := range players {
+		gs.addPlayer(p)
+	}
+	return gs
+}
+
+// getTestPlayer returns the named player as clients would see them
+func getTestPlayer(t *testing.T, gs *gameState, name string) player {
+	e, err := gs.getPlayer(name)
+	require.NoError(t, err)
+	return e.toPlayer()
+}
+
+// entityNames returns the actor names of the given entities
+func entityNames(entities []*entity) []string {
+	names := []string{}
+	for _, e := range entities {
+		names = append(names, e.Actor.Name)
+	}
+	return names
+}
+
 func TestGameState(t *testing.T) {
 	// Create a new gameState
-	gs := gameState{
-		Players: []player{},
-	}
+	gs := newGameState()
 
 	// Add a player
-	gs.Players = append(gs.Players, testPlayer1FacingRight)
+	gs.addPlayer(testPlayer1FacingRight)
 
 	// Add another player
-	gs.Players = append(gs.Players, player{
+	gs.addPlayer(player{
 		X:           50,
 		Y:           0,
 		Name:        "player2",
@@ -64,13 +88,12 @@ func TestGameState(t *testing.T) {
 	})
 
 	// Test playerAttackHit
-	hit, hitName := gs.playerAttackHit("player1")
-	require.Equal(t, true, hit)
-	require.Equal(t, "player2", hitName)
+	hitNames := entityNames(gs.playerAttackHit("player1"))
+	require.Equal(t, []string{"player2"}, hitNames)
 
 	// Test playerAttack
 	gs.playerAttack("player1")
-	require.Equal(t, 90, gs.Players[1].Health)
+	require.Equal(t, 90, getTestPlayer(t, gs, "player2").Health)
 }
 
 var playerAttackHitTestCases = []struct {
@@ -231,12 +254,51 @@ var playerAttackHitTestCases = []struct {
 func TestPlayerAttackHit(t *testing.T) {
 	for _, tc := range playerAttackHitTestCases {
 		t.Run(tc.Name, func(t *testing.T) {
-			gs := gameState{
-				Players: tc.Players,
-			}
+			gs := newTestGameState(tc.Players...)
 
-			hit, _ := gs.playerAttackHit("player1")
-			require.Equal(t, tc.expected, hit)
+			hitNames := gs.playerAttackHit("player1")
+			require.Equal(t, tc.expected, len(hitNames) > 0)
 		})
 	}
 }
+
+func TestPlayerAttackHitMultipleTargets(t *testing.T) {
+	gs := newTestGameState(
+		testPlayer1FacingRight,
+		player{X: playerSpriteWidth + 10, Y: 0, Name: "player2", Health: 100, Facing: "left", Skin: "skin2"},
+		player{X: playerSpriteWidth + 10, Y: 20, Name: "player3", Health: 100, Facing: "left", Skin: "skin2"},
+		player{X: playerSpriteWidth + 2, Y: -10, Name: "player4", Health: 100, Facing: "left", Skin: "skin2", IsDodging: true},
+	)
+
+	// every player in the hitbox is hit, nearest first, except the one dodging
+	require.Equal(t, []string{"player2", "player3"}, entityNames(gs.playerAttackHit("player1")))
+
+	// max targets keeps the nearest
+	attacker, _ := gs.getPlayer("player1")
+	sword := getWeapon("sword")
+	require.Equal(t, []string{"player2"}, entityNames(gs.entitiesHitByShape(attacker, sword.hitShape(attacker), 1)))
+
+	// attack damages everyone hit
+	gs.playerAttack("player1")
+	require.Equal(t, 90, getTestPlayer(t, gs, "player2").Health)
+	require.Equal(t, 90, getTestPlayer(t, gs, "player3").Health)
+	require.Equal(t, 100, getTestPlayer(t, gs, "player4").Health)
+}
+
+func TestJoiningTwiceIsOnePlayer(t *testing.T) {
+	gs := newGameState()
+	gs.Settings.Backfill = 2
+	gs.addPlayer(player{Name: "player1", Facing: "down"})
+	gs.refresh()
+	require.Equal(t, 2, gs.playerCount())
+
+	// joining again as someone in the room carries on as them
+	gs.addPlayer(player{X: 100, Name: "player1", Facing: "down"})
+	require.Equal(t, 2, gs.playerCount())
+	require.Equal(t, 0, getTestPlayer(t, gs, "player1").X)
+
+	// and nobody can join as a bot
+	gs.addPlayer(player{Name: "bot1", Facing: "down"})
+	require.Equal(t, 2, gs.playerCount())
+	require.True(t, getTestPlayer(t, gs, "bot1").IsBot)
+}
@@ -0,0 +1,129 @@
+package main
+
+import "fmt"
+
+// teamSpawnWidth is how much of the map each default team spawns in, from its end
+const teamSpawnWidth = 192
+
+// team is a side players can be on, with its own colour and spawn area
+type team struct {
+	Name  string      `json:"name"`
+	Color string      `json:"color"`
+	Spawn boundingBox `json:"spawn"`
+}
+
+// roomSettings are the rules a room plays by
+type roomSettings struct {
+	// FriendlyFire lets teammates hurt each other
+	FriendlyFire bool `json:"friendlyFire"`
+	// Mode is the game mode being played, empty if the room just plays without scoring
+	Mode string `json:"mode"`
+	// ScoreLimit wins the match, 0 uses the mode's default
+	ScoreLimit int `json:"scoreLimit"`
+	// TimeLimit ends the match after this many milliseconds, 0 for no limit
+	TimeLimit int64 `json:"timeLimit"`
+	// MinPlayers is how many ready players it takes to start a match, 0 uses the default
+	MinPlayers int `json:"minPlayers"`
+	// Backfill adds bots until the room has this many players, while anyone is playing
+	// it and BotDifficulty are set by operators, hosts can't change them
+	Backfill int `json:"backfill"`
+	// BotDifficulty is how well the bots play: easy, normal or hard
+	BotDifficulty string `json:"botDifficulty"`
+}
+
+// validateBots checks the bot settings, which operators set from flags and scenarios
+func (s roomSettings) validateBots() error {
+	if s.Backfill < 0 || s.Backfill > maxBackfill {
+		return fmt.Errorf("backfill must be between 0 and %d", maxBackfill)
+	}
+	if _, ok := botDifficulties[s.BotDifficulty]; s.BotDifficulty != "" && !ok {
+		return fmt.Errorf("unknown bot difficulty %q", s.BotDifficulty)
+	}
+	return nil
+}
+
+// defaultTeams splits the map between two teams spawning at opposite ends of it
+func defaultTeams(bounds boundingBox) []team {
+	return []team{
+		{
+			Name:  "red",
+			Color: "#d64545",
+			Spawn: boundingBox{X: bounds.X, Y: bounds.Y, Width: teamSpawnWidth, Height: bounds.Height},
+		},
+		{
+			Name:  "blue",
+			Color: "#4565d6",
+			Spawn: boundingBox{X: bounds.X + bounds.Width - teamSpawnWidth, Y: bounds.Y, Width: teamSpawnWidth, Height: bounds.Height},
+		},
+	}
+}
+
+func (gs *gameState) getTeam(name string) (team, bool) {
+	for _, t := range gs.Teams {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return team{}, false
+}
+
+func (gs *gameState) teamSize(name string) int {
+	size := 0
+	for _, e := range gs.entities {
+		if e.Kind == "player" && e.Actor.Team == name {
+			size++
+		}
+	}
+	return size
+}
+
+// selectTeam returns the team a joining player should be on,
+// the one they asked for if there is one, otherwise the smallest
+func (gs *gameState) selectTeam(name string) (team, bool) {
+	if t, ok := gs.getTeam(name); ok {
+		return t, true
+	}
+	if len(gs.Teams) == 0 {
+		return team{}, false
+	}
+
+	smallest := gs.Teams[0]
+	for _, t := range gs.Teams[1:] {
+		if gs.teamSize(t.Name) < gs.teamSize(smallest.Name) {
+			smallest = t
+		}
+	}
+	return smallest, true
+}
+
+// spawnInArea moves the entity to the first free position in the area, scanning row by row
+// it is left where it is if the area is full
+func (gs *gameState) spawnInArea(e *entity, area boundingBox) {
+	sprite := e.spriteBox()
+	for y := area.Y; y+sprite.Height <= area.Y+area.Height; y += sprite.Height {
+		for x := area.X; x+sprite.Width <= area.X+area.Width; x += sprite.Width {
+			if !gs.blocked(e, x, y) {
+				e.Position.X = x
+				e.Position.Y = y
+				return
+			}
+		}
+	}
+}
+
+// teammates reports whether both entities are actors on the same team
+func teammates(a, b *entity) bool {
+	return a.Actor != nil && b.Actor != nil && a.Actor.Team != "" && a.Actor.Team == b.Actor.Team
+}
+
+// canHurt reports whether attacks from someone on the team can hurt the target
+// players can't be hurt while they wait in the lobby
+func (gs *gameState) canHurt(attackerTeam string, target *entity) bool {
+	if gs.inLobby() && target.Kind == "player" {
+		return false
+	}
+	if gs.Settings.FriendlyFire || attackerTeam == "" || target.Actor == nil {
+		return true
+	}
+	return target.Actor.Team != attackerTeam
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTeamGameState creates a gameState with the default teams, and the given players joined in order
+func newTestTeamGameState(players ...player) *gameState {
+	gs := newGameState()
+	gs.Teams = defaultTeams(gs.Bounds)
+	for _, p := range players {
+		gs.addPlayer(p)
+	}
+	return gs
+}
+
+func TestJoinBalancesTeams(t *testing.T) {
+	gs := newTestTeamGameState(
+		player{Name: "player1", Facing: "down"},
+		player{Name: "player2", Facing: "down"},
+		player{Name: "player3", Team: "blue", Facing: "down"},
+		player{Name: "player4", Team: "green", Facing: "down"},
+	)
+
+	require.Equal(t, "red", getTestPlayer(t, gs, "player1").Team)
+	require.Equal(t, "blue", getTestPlayer(t, gs, "player2").Team)
+	require.Equal(t, "blue", getTestPlayer(t, gs, "player3").Team)
+	// unknown teams are ignored in favour of the smallest one
+	require.Equal(t, "red", getTestPlayer(t, gs, "player4").Team)
+}
+
+func TestJoinWithoutTeams(t *testing.T) {
+	gs := newTestGameState(player{X: 300, Y: 200, Name: "player1", Team: "red", Facing: "down"})
+	p := getTestPlayer(t, gs, "player1")
+	require.Equal(t, "", p.Team)
+	require.Equal(t, 300, p.X)
+	require.Equal(t, 200, p.Y)
+}
+
+func TestTeamsSpawnInTheirArea(t *testing.T) {
+	gs := newTestTeamGameState(
+		player{X: 900, Y: 500, Name: "player1", Team: "red", Facing: "down"},
+		player{X: 900, Y: 500, Name: "player2", Team: "red", Facing: "down"},
+		player{X: 0, Y: 0, Name: "player3", Team: "blue", Facing: "down"},
+	)
+
+	for _, name := range []string{"player1", "player2", "player3"} {
+		p, _ := gs.getPlayer(name)
+		spawn := gs.Teams[0].Spawn
+		if p.Actor.Team == "blue" {
+			spawn = gs.Teams[1].Spawn
+		}
+		sprite := p.spriteBox()
+		require.GreaterOrEqual(t, sprite.X, spawn.X, name)
+		require.LessOrEqual(t, sprite.X+sprite.Width, spawn.X+spawn.Width, name)
+		require.GreaterOrEqual(t, sprite.Y, spawn.Y, name)
+		require.LessOrEqual(t, sprite.Y+sprite.Height, spawn.Y+spawn.Height, name)
+	}
+
+	// teammates don't spawn on top of each other
+	p1, _ := gs.getPlayer("player1")
+	p2, _ := gs.getPlayer("player2")
+	require.False(t, boxesOverlap(p1.hitboxBox(), p2.hitboxBox()))
+}
+
+func TestFriendlyFire(t *testing.T) {
+	tests := []struct {
+		name         string
+		friendlyFire bool
+		team         string
+		hit          []string
+	}{
+		{"teammate", false, "red", []string{}},
+		{"teammate with friendly fire", true, "red", []string{"player2"}},
+		{"enemy", false, "blue", []string{"player2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gs := newTestTeamGameState(
+				player{Name: "player1", Team: "red", Facing: "right"},
+				player{Name: "player2", Team: test.team, Facing: "left"},
+			)
+			gs.Settings.FriendlyFire = test.friendlyFire
+
+			// line them up next to each other in the middle of the map
+			p1, _ := gs.getPlayer("player1")
+			p2, _ := gs.getPlayer("player2")
+			p1.Position = &positionComponent{X: 500, Y: 500}
+			p2.Position = &positionComponent{X: 500 + playerSpriteWidth + 5, Y: 500}
+			require.Equal(t, test.hit, entityNames(gs.playerAttackHit("player1")))
+
+			// projectiles follow the same rules
+			p1.Actor.Weapon = "bow"
+			p2.Position.X = 600
+			gs.playerAttack("player1")
+			for i := 0; i < 20 && len(getTestProjectiles(gs)) > 0; i++ {
+				projectileSystem(gs)
+			}
+			if len(test.hit) == 0 {
+				require.Equal(t, p2.Health.Max, p2.Health.Current)
+			} else {
+				require.Less(t, p2.Health.Current, p2.Health.Max)
+			}
+		})
+	}
+}
+
+func TestTeamColorsInSnapshot(t *testing.T) {
+	gs := newTestTeamGameState(
+		player{Name: "player1", Team: "red", Facing: "down"},
+		player{Name: "player2", Team: "blue", Facing: "down"},
+	)
+
+	decoded := struct {
+		Players []player `json:"players"`
+		Teams   []team   `json:"teams"`
+	}{}
+	require.NoError(t, json.Unmarshal(gs.toJSON("player1"), &decoded))
+	require.Equal(t, gs.Teams, decoded.Teams)
+	require.Equal(t, "red", decoded.Players[0].Team)
+	require.Equal(t, gs.Teams[0].Color, decoded.Players[0].TeamColor)
+	require.Equal(t, gs.Teams[1].Color, decoded.Players[1].TeamColor)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tuning is the gameplay numbers a tuning file can change, every room has its own copy
+type tuning struct {
+	// WalkDistance is how far actors walk each step, before their class and effects change it
+	WalkDistance  int          `yaml:"walkDistance" json:"walkDistance"`
+	DodgeDistance int          `yaml:"dodgeDistance" json:"dodgeDistance"`
+	DodgeCost     resourceCost `yaml:"dodgeCost" json:"dodgeCost"`
+	// timeouts are how long walking and dodging last in milliseconds
+	WalkTimeout  int64 `yaml:"walkTimeout" json:"walkTimeout"`
+	DodgeTimeout int64 `yaml:"dodgeTimeout" json:"dodgeTimeout"`
+	// Sprite and Hitbox are the size of every actor's body
+	Sprite size `yaml:"sprite" json:"sprite"`
+	Hitbox size `yaml:"hitbox" json:"hitbox"`
+	// Weapons change the damage and cost of weapons from the weapons file
+	Weapons map[string]weaponTuning `yaml:"weapons" json:"weapons,omitempty"`
+}
+
+type size struct {
+	Width  int `yaml:"width" json:"width"`
+	Height int `yaml:"height" json:"height"`
+}
+
+// weaponTuning overrides a weapon's damage and cost, anything left out keeps the weapon's own
+type weaponTuning struct {
+	Damage *int         `yaml:"damage" json:"damage,omitempty"`
+	Cost   resourceCost `yaml:"cost" json:"cost,omitempty"`
+}
+
+// defaultTuning is how the game plays without a tuning file
+var defaultTuning = tuning{
+	WalkDistance:  playerWalkDistance,
+	DodgeDistance: playerDodgeDistance,
+	DodgeCost:     resourceCost{"stamina": 30},
+	WalkTimeout:   250,
+	DodgeTimeout:  300,
+	Sprite:        size{Width: playerSpriteWidth, Height: playerSpriteHeight},
+	Hitbox:        size{Width: playerHitboxWidth, Height: playerHitboxHeight},
+}
+
+// tuningConfig is a parsed tuning file, the tuning every room plays with and the overrides for particular rooms
+type tuningConfig struct {
+	base  tuning
+	rooms map[string]tuning
+}
+
+// tuningFile is the layout of a tuning file, rooms hold just the numbers they change
+// a weapon a room mentions replaces the file's changes to that weapon rather than adding to them
+type tuningFile struct {
+	tuning `yaml:",inline"`
+	Rooms  map[string]yaml.Node `yaml:"rooms"`
+}
+
+func loadTuning(path string) (tuningConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tuningConfig{}, fmt.Errorf("read tuning: %w", err)
+	}
+	return parseTuning(data)
+}
+
+// parseTuning reads a tuning file over the default tuning, then each room's overrides over that
+func parseTuning(data []byte) (tuningConfig, error) {
+	file, err := decodeTuningFile(data)
+	if err != nil {
+		return tuningConfig{}, err
+	}
+	if err := file.tuning.validate(); err != nil {
+		return tuningConfig{}, fmt.Errorf("tuning: %w", err)
+	}
+
+	config := tuningConfig{base: file.tuning, rooms: map[string]tuning{}}
+	for _, name := range sortedKeys(file.Rooms) {
+		// every room starts from its own copy of the file, so overrides don't leak into the maps of other rooms
+		room, err := decodeTuningFile(data)
+		if err != nil {
+			return tuningConfig{}, err
+		}
+		node := file.Rooms[name]
+		overrides, err := yaml.Marshal(&node)
+		if err != nil {
+			return tuningConfig{}, fmt.Errorf("room %q: %w", name, err)
+		}
+		err = decodeStrict(overrides, &room.tuning)
+		if err != nil {
+			return tuningConfig{}, fmt.Errorf("parse tuning for room %q: %w", name, err)
+		}
+		if err := room.tuning.validate(); err != nil {
+			return tuningConfig{}, fmt.Errorf("tuning for room %q: %w", name, err)
+		}
+		config.rooms[name] = room.tuning
+	}
+	return config, nil
+}
+
+func decodeTuningFile(data []byte) (tuningFile, error) {
+	file := tuningFile{tuning: defaultTuning}
+	// the default dodge cost is shared, so it is swapped for a copy before the file can add to it
+	file.DodgeCost = resourceCost{}
+	for name, amount := range defaultTuning.DodgeCost {
+		file.DodgeCost[name] = amount
+	}
+	err := decodeStrict(data, &file)
+	if err != nil {
+		return file, fmt.Errorf("parse tuning: %w", err)
+	}
+	return file, nil
+}
+
+// decodeStrict decodes yaml, complaining about fields it doesn't know so typos don't go unnoticed
+func decodeStrict(data []byte, value any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(value)
+	// an empty file changes nothing
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func (t tuning) validate() error {
+	if t.WalkDistance <= 0 {
+		return fmt.Errorf("walk distance must be positive")
+	}
+	if t.DodgeDistance < 0 {
+		return fmt.Errorf("dodge distance cannot be negative")
+	}
+	if err := t.DodgeCost.validate(); err != nil {
+		return fmt.Errorf("dodge: %w", err)
+	}
+	if t.WalkTimeout <= 0 || t.DodgeTimeout <= 0 {
+		return fmt.Errorf("walk and dodge timeouts must be positive")
+	}
+	if t.Sprite.Width <= 0 || t.Sprite.Height <= 0 || t.Hitbox.Width <= 0 || t.Hitbox.Height <= 0 {
+		return fmt.Errorf("sprite and hitbox sizes must be positive")
+	}
+	if t.Hitbox.Width > t.Sprite.Width || t.Hitbox.Height > t.Sprite.Height {
+		return fmt.Errorf("hitbox cannot be bigger than the sprite")
+	}
+	for _, name := range sortedKeys(t.Weapons) {
+		if _, ok := weapons[name]; !ok {
+			return fmt.Errorf("unknown weapon %q", name)
+		}
+		w := t.Weapons[name]
+		if w.Damage != nil && *w.Damage <= 0 {
+			return fmt.Errorf("weapon %q: damage must be positive", name)
+		}
+		if err := w.Cost.validate(); err != nil {
+			return fmt.Errorf("weapon %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// forRoom returns the tuning the named room plays with
+func (c tuningConfig) forRoom(name string) tuning {
+	if t, ok := c.rooms[name]; ok {
+		return t
+	}
+	return c.base
+}
+
+// fitBody sizes the actor's sprite and hitbox, keeping the hitbox centered on the sprite
+func (t tuning) fitBody(e *entity) {
+	e.Sprite.Width = t.Sprite.Width
+	e.Sprite.Height = t.Sprite.Height
+	e.Hitbox.OffsetX = (t.Sprite.Width - t.Hitbox.Width) / 2
+	e.Hitbox.OffsetY = (t.Sprite.Height - t.Hitbox.Height) / 2
+	e.Hitbox.Width = t.Hitbox.Width
+	e.Hitbox.Height = t.Hitbox.Height
+}
+
+// setTuning changes the room's tuning while it is running, resizing everyone already in it
+func (gs *gameState) setTuning(t tuning) {
+	gs.tuning = t
+	for _, e := range gs.entities {
+		if e.Actor != nil {
+			t.fitBody(e)
+		}
+	}
+	// the navigation grid is built for the hitbox size
+	gs.navGrid = nil
+}
+
+// weapon returns the named weapon with the room's tuning applied
+func (gs *gameState) weapon(name string) weapon {
+	w := getWeapon(name)
+	override, ok := gs.tuning.Weapons[w.Name]
+	if !ok {
+		return w
+	}
+	if override.Damage != nil {
+		w.Damage = *override.Damage
+	}
+	if override.Cost != nil {
+		w.Cost = override.Cost
+	}
+	return w
+}
+
+// setTuning gives every open room its tuning from the config, and rooms opened later too
+// rooms playing back a replay keep the tuning they were recorded with
+func (r *roomRegistry) setTuning(config tuningConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tuning = &config
+	for name, rm := range r.rooms {
+		rm.mu.Lock()
+		if rm.playback == nil {
+			rm.setTuning(config.forRoom(name))
+		}
+		rm.mu.Unlock()
+	}
+}
+
+// setTuning changes the tuning of the locked room, recording the change so replays change it at the same point
+func (rm *room) setTuning(t tuning) {
+	if rm.recorder != nil {
+		rm.recorder.recordTuning(t, rm.state.now())
+	}
+	rm.state.setTuning(t)
+}
+
+// reloadTuning loads the tuning file into every room, leaving them as they are if it isn't valid
+func (r *roomRegistry) reloadTuning(path string) error {
+	config, err := loadTuning(path)
+	if err != nil {
+		return err
+	}
+	r.setTuning(config)
+	return nil
+}
+
+// watchTuning reloads the tuning file whenever it changes or the server gets SIGHUP
+// connections aren't touched, rooms just play by the new numbers from their next event
+func (r *roomRegistry) watchTuning(path string, interval time.Duration) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	modified := modTime(path)
+	for {
+		select {
+		case <-hangups:
+		case <-ticker.C:
+			if modTime(path).Equal(modified) {
+				continue
+			}
+		}
+		modified = modTime(path)
+		err := r.reloadTuning(path)
+		if err != nil {
+			log.Println("reload tuning:", err)
+			continue
+		}
+		log.Println("reloaded tuning from", path)
+	}
+}
+
+// modTime returns when the file was last changed, the zero time if it can't be read
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
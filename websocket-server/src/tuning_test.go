@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTuning(t *testing.T) {
+	config, err := loadTuning("testdata/tuning.yaml")
+	require.NoError(t, err)
+
+	base := config.forRoom("default")
+	require.Equal(t, 3, base.WalkDistance)
+	require.Equal(t, 32, base.DodgeDistance)
+	require.Equal(t, resourceCost{"stamina": 20}, base.DodgeCost)
+	require.Equal(t, 15, *base.Weapons["sword"].Damage)
+
+	// rooms only change what they mention
+	arena := config.forRoom("arena")
+	require.Equal(t, 4, arena.WalkDistance)
+	require.Equal(t, 32, arena.DodgeDistance)
+	// but a weapon they mention replaces the file's changes to it
+	require.Equal(t, 30, *arena.Weapons["sword"].Damage)
+	require.Nil(t, arena.Weapons["sword"].Cost)
+
+	// an empty file is the default tuning
+	config, err = parseTuning([]byte(""))
+	require.NoError(t, err)
+	require.Equal(t, defaultTuning, config.forRoom("default"))
+	require.Equal(t, resourceCost{"stamina": 30}, defaultTuning.DodgeCost)
+}
+
+func TestParseTuningErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{name: "bad yaml", yaml: "walkDistance: [", err: "parse tuning"},
+		{name: "unknown field", yaml: "walkSpeed: 3", err: "walkSpeed"},
+		{name: "walk distance", yaml: "walkDistance: 0", err: "walk distance must be positive"},
+		{name: "dodge distance", yaml: "dodgeDistance: -1", err: "dodge distance cannot be negative"},
+		{name: "dodge cost", yaml: "dodgeCost: {stamina: -5}", err: "stamina cost cannot be negative"},
+		{name: "timeout", yaml: "dodgeTimeout: 0", err: "timeouts must be positive"},
+		{name: "sprite", yaml: "sprite: {width: 0, height: 48}", err: "sizes must be positive"},
+		{name: "hitbox", yaml: "hitbox: {width: 64, height: 12}", err: "hitbox cannot be bigger than the sprite"},
+		{name: "unknown weapon", yaml: "weapons: {wand: {damage: 5}}", err: `unknown weapon "wand"`},
+		{name: "weapon damage", yaml: "weapons: {sword: {damage: -5}}", err: "damage must be positive"},
+		{name: "no weapon damage", yaml: "weapons: {sword: {damage: 0}}", err: "damage must be positive"},
+		{name: "room", yaml: "rooms: {arena: {walkDistance: -2}}", err: `tuning for room "arena": walk distance must be positive`},
+		{name: "room unknown field", yaml: "rooms: {arena: {speed: 2}}", err: `room "arena"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseTuning([]byte(tc.yaml))
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestTuningGameplay(t *testing.T) {
+	gs, c := newTestClockGameState(
+		player{X: 100, Y: 100, Name: "player1", Facing: "down", Health: 100, Stamina: 100},
+		player{X: 100, Y: 100 + playerSpriteHeight + 5, Name: "player2", Facing: "down", Health: 100, Stamina: 100},
+	)
+	damage := 25
+	gs.setTuning(tuning{
+		WalkDistance:  5,
+		DodgeDistance: 40,
+		DodgeCost:     resourceCost{"stamina": 10},
+		WalkTimeout:   250,
+		DodgeTimeout:  300,
+		Sprite:        size{Width: 64, Height: 64},
+		Hitbox:        size{Width: 32, Height: 16},
+		Weapons:       map[string]weaponTuning{"sword": {Damage: &damage}},
+	})
+
+	// players already in the room are resized
+	p, err := gs.getPlayer("player1")
+	require.NoError(t, err)
+	require.Equal(t, spriteComponent{Width: 64, Height: 64, Skin: p.Sprite.Skin}, *p.Sprite)
+	require.Equal(t, hitboxComponent{OffsetX: 16, OffsetY: 24, Width: 32, Height: 16, Solid: true}, *p.Hitbox)
+
+	gs.handleEvent(gameEvent{Type: "attack", Data: eventData{player: player{Name: "player1"}}})
+	require.Equal(t, 100-damage, getTestPlayer(t, gs, "player2").Health)
+
+	gs.handleEvent(gameEvent{Type: "walk", Data: eventData{player: player{Name: "player2", Facing: "right"}}})
+	require.Equal(t, 105, getTestPlayer(t, gs, "player2").X)
+
+	c.advance(1000)
+	gs.refresh()
+	gs.handleEvent(gameEvent{Type: "dodge", Data: eventData{player: player{Name: "player2", Facing: "right"}}})
+	moved := getTestPlayer(t, gs, "player2")
+	require.Equal(t, 145, moved.X)
+	require.Equal(t, 90, moved.Resources["stamina"].Current)
+}
+
+func TestReloadTuning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tuning.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("walkDistance: 3"), 0o644))
+
+	rooms := newRoomRegistry(newGameState)
+	lobby := rooms.get("lobby")
+	require.NoError(t, rooms.reloadTuning(path))
+	require.Equal(t, 3, lobby.state.tuning.WalkDistance)
+
+	// rooms opened later get the tuning too, with their own overrides
+	require.NoError(t, os.WriteFile(path, []byte("walkDistance: 4\nrooms: {arena: {walkDistance: 6}}"), 0o644))
+	require.NoError(t, rooms.reloadTuning(path))
+	require.Equal(t, 4, lobby.state.tuning.WalkDistance)
+	require.Equal(t, 6, rooms.get("arena").state.tuning.WalkDistance)
+
+	// a broken file leaves every room as it was
+	require.NoError(t, os.WriteFile(path, []byte("walkDistance: 0"), 0o644))
+	require.Error(t, rooms.reloadTuning(path))
+	require.Equal(t, 4, lobby.state.tuning.WalkDistance)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+)
+
+const defaultWeapon = "sword"
+
+//go:embed weapons.json
+var defaultWeaponsJSON []byte
+
+// weapons available to players, replaced at startup if a weapons file is given
+var weapons = mustParseWeapons(defaultWeaponsJSON)
+
+type weapon struct {
+	Name          string       `json:"-"`
+	Damage        int          `json:"damage"`
+	Reach         int          `json:"reach"`
+	Shape         string       `json:"shape"`
+	Width         int          `json:"width"`
+	Spread        float64      `json:"spread"`
+	Cost          resourceCost `json:"cost"`
+	SwingDuration int64        `json:"swingDuration"`
+	MaxTargets    int          `json:"maxTargets"`
+	// Projectile makes this a ranged weapon that fires the named projectile kind
+	Projectile string `json:"projectile"`
+	// Effects are applied to everything the weapon hits
+	Effects []string `json:"effects"`
+}
+
+func loadWeapons(path string) (map[string]weapon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read weapons: %w", err)
+	}
+	return parseWeapons(data)
+}
+
+func mustParseWeapons(data []byte) map[string]weapon {
+	parsed, err := parseWeapons(data)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func parseWeapons(data []byte) (map[string]weapon, error) {
+	parsed := map[string]weapon{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("parse weapons: %w", err)
+	}
+
+	if _, ok := parsed[defaultWeapon]; !ok {
+		return nil, fmt.Errorf("weapons must include %q", defaultWeapon)
+	}
+
+	// validate in name order so errors are reported consistently
+	names := []string{}
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w := parsed[name]
+		w.Name = name
+		err := w.validate()
+		if err != nil {
+			return nil, fmt.Errorf("weapon %q: %w", name, err)
+		}
+		parsed[name] = w
+	}
+
+	return parsed, nil
+}
+
+func (w weapon) validate() error {
+	if w.Damage <= 0 {
+		return fmt.Errorf("damage must be positive")
+	}
+	if err := w.Cost.validate(); err != nil {
+		return err
+	}
+	if w.SwingDuration <= 0 {
+		return fmt.Errorf("swing duration must be positive")
+	}
+	if w.MaxTargets < 0 {
+		return fmt.Errorf("max targets cannot be negative")
+	}
+	if err := validateEffects(w.Effects); err != nil {
+		return err
+	}
+
+	// ranged weapons don't need a hitbox
+	if w.Projectile != "" {
+		if _, ok := projectileKinds[w.Projectile]; !ok {
+			return fmt.Errorf("unknown projectile %q", w.Projectile)
+		}
+		return nil
+	}
+
+	if w.Reach <= 0 {
+		return fmt.Errorf("reach must be positive")
+	}
+
+	switch w.Shape {
+	case "rect":
+		if w.Width < 0 {
+			return fmt.Errorf("width cannot be negative")
+		}
+	case "circle":
+	case "arc":
+		if w.Spread <= 0 || w.Spread > 180 {
+			return fmt.Errorf("spread must be between 0 and 180 degrees")
+		}
+	default:
+		return fmt.Errorf("unknown shape %q", w.Shape)
+	}
+
+	return nil
+}
+
+func getWeapon(name string) weapon {
+	w, ok := weapons[name]
+	if !ok {
+		return weapons[defaultWeapon]
+	}
+	return w
+}
+
+// hitShape returns the area the weapon hits when swung by the given actor
+func (w weapon) hitShape(e *entity) hitShape {
+	sprite := e.spriteBox()
+	facing := e.Actor.Facing
+	centerX := sprite.X + sprite.Width/2
+	centerY := sprite.Y + sprite.Height/2
+
+	switch w.Shape {
+	case "circle":
+		return circleHitShape{
+			centerX: centerX,
+			centerY: centerY,
+			radius:  sprite.Width/2 + w.Reach,
+		}
+	case "arc":
+		return arcHitShape{
+			centerX:   centerX,
+			centerY:   centerY,
+			radius:    sprite.Width/2 + w.Reach,
+			direction: facingAngle(facing),
+			spread:    w.Spread * math.Pi / 180,
+		}
+	}
+
+	// rect reaches out from the side of the sprite the player is facing
+	// width is measured across the facing direction and defaults to the sprite size
+	width := w.Width
+	horizontal := facing == "left" || facing == "right"
+	if width == 0 {
+		width = sprite.Width
+		if horizontal {
+			width = sprite.Height
+		}
+	}
+	offsetX := (sprite.Width - width) / 2
+	offsetY := (sprite.Height - width) / 2
+
+	hitbox := rectHitShape{}
+	switch facing {
+	case "up":
+		hitbox.topLeftCornerX = sprite.X + offsetX
+		hitbox.topLeftCornerY = sprite.Y - w.Reach
+		hitbox.bottomRightCornerX = sprite.X + offsetX + width
+		hitbox.bottomRightCornerY = sprite.Y
+	case "down":
+		hitbox.topLeftCornerX = sprite.X + offsetX
+		hitbox.topLeftCornerY = sprite.Y + sprite.Height
+		hitbox.bottomRightCornerX = sprite.X + offsetX + width
+		hitbox.bottomRightCornerY = sprite.Y + sprite.Height + w.Reach
+	case "left":
+		hitbox.topLeftCornerX = sprite.X - w.Reach
+		hitbox.topLeftCornerY = sprite.Y + offsetY
+		hitbox.bottomRightCornerX = sprite.X
+		hitbox.bottomRightCornerY = sprite.Y + offsetY + width
+	case "right":
+		hitbox.topLeftCornerX = sprite.X + sprite.Width
+		hitbox.topLeftCornerY = sprite.Y + offsetY
+		hitbox.bottomRightCornerX = sprite.X + sprite.Width + w.Reach
+		hitbox.bottomRightCornerY = sprite.Y + offsetY + width
+	}
+	return hitbox
+}
+
+// selectWeapon returns the weapon name to give a joining player
+func selectWeapon(name string) string {
+	if name == "" {
+		return defaultWeapon
+	}
+	if _, ok := weapons[name]; !ok {
+		log.Println("unknown weapon:", name)
+		return defaultWeapon
+	}
+	return name
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultWeapons(t *testing.T) {
+	for _, name := range []string{"sword", "spear", "hammer", "dagger"} {
+		w, ok := weapons[name]
+		require.True(t, ok, name)
+		require.Equal(t, name, w.Name)
+	}
+
+	// unknown weapons fall back to the default
+	require.Equal(t, defaultWeapon, selectWeapon("banana"))
+	require.Equal(t, defaultWeapon, selectWeapon(""))
+	require.Equal(t, "spear", selectWeapon("spear"))
+}
+
+var parseWeaponsTestCases = []struct {
+	Name  string
+	JSON  string
+	Error string
+}{
+	{
+		Name:  "missingDefault",
+		JSON:  `{"spear": {"damage": 1, "reach": 1, "shape": "rect", "swingDuration": 1}}`,
+		Error: `weapons must include "sword"`,
+	},
+	{
+		Name:  "zeroDamage",
+		JSON:  `{"sword": {"damage": 0, "reach": 1, "shape": "rect", "swingDuration": 1}}`,
+		Error: `weapon "sword": damage must be positive`,
+	},
+	{
+		Name:  "unknownShape",
+		JSON:  `{"sword": {"damage": 1, "reach": 1, "shape": "triangle", "swingDuration": 1}}`,
+		Error: `weapon "sword": unknown shape "triangle"`,
+	},
+	{
+		Name:  "arcWithoutSpread",
+		JSON:  `{"sword": {"damage": 1, "reach": 1, "shape": "arc", "swingDuration": 1}}`,
+		Error: `weapon "sword": spread must be between 0 and 180 degrees`,
+	},
+	{
+		Name:  "negativeStaminaCost",
+		JSON:  `{"sword": {"damage": 1, "reach": 1, "shape": "rect", "cost": {"stamina": -1}, "swingDuration": 1}}`,
+		Error: `weapon "sword": stamina cost cannot be negative`,
+	},
+	{
+		Name:  "unknownProjectile",
+		JSON:  `{"sword": {"damage": 1, "projectile": "rock", "swingDuration": 1}}`,
+		Error: `weapon "sword": unknown projectile "rock"`,
+	},
+	{
+		Name: "valid",
+		JSON: `{"sword": {"damage": 1, "reach": 1, "shape": "circle", "swingDuration": 1}}`,
+	},
+}
+
+func TestParseWeapons(t *testing.T) {
+	for _, tc := range parseWeaponsTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := parseWeapons([]byte(tc.JSON))
+			if tc.Error == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.Error)
+		})
+	}
+}
+
+func TestWeaponReach(t *testing.T) {
+	target := player{X: playerSpriteWidth + 30, Y: 0, Name: "player2", Health: 100, Facing: "left", Skin: "skin2"}
+
+	// a spear reaches further than a sword
+	for _, tc := range []struct {
+		weapon   string
+		expected []string
+	}{
+		{weapon: "sword", expected: []string{}},
+		{weapon: "spear", expected: []string{"player2"}},
+	} {
+		attacker := testPlayer1FacingRight
+		attacker.Weapon = tc.weapon
+		gs := newTestGameState(attacker, target)
+		require.Equal(t, tc.expected, entityNames(gs.playerAttackHit("player1")), tc.weapon)
+	}
+
+	// a hammer hits all around the attacker
+	attacker := testPlayer1FacingRight
+	attacker.Weapon = "hammer"
+	behind := player{X: -playerSpriteWidth - 10, Y: 0, Name: "player3", Health: 100, Facing: "right", Skin: "skin2"}
+	gs := newTestGameState(attacker, behind)
+	require.Equal(t, []string{"player3"}, entityNames(gs.playerAttackHit("player1")))
+}
+
+func TestPlayerAttackUsesWeapon(t *testing.T) {
+	attacker := testPlayer1FacingRight
+	attacker.Weapon = "hammer"
+	gs := newTestGameState(
+		attacker,
+		player{X: playerSpriteWidth + 5, Y: 0, Name: "player2", Health: 100, Facing: "left", Skin: "skin2"},
+	)
+
+	gs.playerAttack("player1")
+	require.Equal(t, 100-getWeapon("hammer").Damage, getTestPlayer(t, gs, "player2").Health)
+	require.Equal(t, 100-getWeapon("hammer").Cost["stamina"], getTestPlayer(t, gs, "player1").Stamina)
+
+	// a second attack during the swing does nothing
+	gs.playerAttack("player1")
+	require.Equal(t, 100-getWeapon("hammer").Damage, getTestPlayer(t, gs, "player2").Health)
+}
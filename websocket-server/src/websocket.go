@@ -2,17 +2,29 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type WebsocketServer struct {
-	addr      string
-	cors      string
-	gameState *gameState
+	addr  string
+	cors  string
+	rooms *roomRegistry
+	// replay is the room playing back a replay, every connection spectates it when there is one
+	replay *room
+	// admin serves the admin api, if there is an admin token to protect it with
+	admin  *adminAPI
+	server *http.Server
+	// saveDir is where rooms are saved on shutdown, empty to let them go
+	saveDir string
+	// shutdownGrace is how long players get to see the shutdown message before they are disconnected
+	shutdownGrace time.Duration
 }
 
 var upgrader = websocket.Upgrader{
@@ -29,12 +41,41 @@ var upgrader = websocket.Upgrader{
 
 func (wss WebsocketServer) state(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", wss.cors)
+	if wss.rooms.isClosing() {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
 	c, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Print("upgrade:", err)
 		return
 	}
 	defer c.Close()
+	connectedClients.WithLabelValues("player").Inc()
+	defer connectedClients.WithLabelValues("player").Dec()
+
+	// servers playing back a replay only have the replay to watch
+	if wss.replay != nil {
+		if !wss.replay.connect(c) {
+			closeConnection(c, websocket.CloseServiceRestart, shutdownMessage)
+			return
+		}
+		defer wss.replay.disconnect(c)
+		wss.spectate(c, wss.replay)
+		return
+	}
+	// clients pick their room when they connect, for example /state?room=arena
+	rm, err := wss.rooms.connect(r.URL.Query().Get("room"), c)
+	if errors.Is(err, errTooManyRooms) {
+		closeConnection(c, websocket.CloseTryAgainLater, err.Error())
+		return
+	}
+	if err != nil {
+		closeConnection(c, websocket.CloseServiceRestart, shutdownMessage)
+		return
+	}
+	defer wss.rooms.disconnect(rm, c)
+
 	for {
 		mt, message, err := c.ReadMessage()
 		if err != nil {
@@ -47,27 +88,83 @@ func (wss WebsocketServer) state(w http.ResponseWriter, r *http.Request) {
 		err = json.Unmarshal(message, &event)
 		if err != nil {
 			log.Println("json unmarshal:", err)
+			droppedEvents.WithLabelValues("invalid json").Inc()
 			break
 		}
-		wss.gameState.handleEvent(event)
-
-		err = c.WriteMessage(mt, wss.gameState.toJSON())
+		countEvent(event.Type)
+		// spectators stop sending events for snapshots, the room sends them instead
+		if event.Type == "spectate" && rm.joined(c) == "" {
+			wss.spectate(c, rm)
+			return
+		}
+		// the room decides who the connection is playing as, clients can't act for anyone else
+		snapshot := rm.handlePlayer(c, event)
+		err = c.WriteMessage(mt, snapshot)
 		if err != nil {
 			log.Println("write:", err)
 			break
 		}
+		countSent("snapshot", snapshot)
+	}
+}
+
+// spectate serves a connection watching the room, sending it every broadcast snapshot
+// events from spectators only move their camera, so they can't change the game
+func (wss WebsocketServer) spectate(c *websocket.Conn, rm *room) {
+	s := rm.spectate()
+	defer rm.stopSpectating(s)
+	connectedClients.WithLabelValues("player").Dec()
+	connectedClients.WithLabelValues("spectator").Inc()
+	defer func() {
+		connectedClients.WithLabelValues("spectator").Dec()
+		connectedClients.WithLabelValues("player").Inc()
+	}()
+
+	// only one goroutine writes to the connection, and it stops once the room stops sending
+	go func() {
+		for snapshot := range s.snapshots {
+			err := c.WriteMessage(websocket.TextMessage, snapshot)
+			if err != nil {
+				log.Println("write:", err)
+				c.Close()
+				return
+			}
+			countSent("broadcast", snapshot)
+		}
+	}()
+
+	for {
+		_, message, err := c.ReadMessage()
+		if err != nil {
+			log.Println("read:", err)
+			return
+		}
+		event := gameEvent{}
+		err = json.Unmarshal(message, &event)
+		if err != nil {
+			log.Println("json unmarshal:", err)
+			droppedEvents.WithLabelValues("invalid json").Inc()
+			return
+		}
+		countEvent(event.Type)
+		rm.spectatorEvent(s, event)
 	}
 }
 
 func (wss WebsocketServer) start() error {
 	http.HandleFunc("/state", wss.state)
+	http.Handle("/metrics", promhttp.Handler())
+	if wss.admin != nil {
+		http.Handle("/admin/", wss.admin.handler())
+	}
 	fmt.Println("Websocket server starting on", wss.addr)
-	err := http.ListenAndServe(wss.addr, nil)
-	if err != nil {
+	err := wss.server.ListenAndServe()
+	// shutting down stops the server, which isn't a problem
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("ListenAndServe: %v", err)
 	}
 
-	fmt.Println("Websocket server started on", wss.addr)
+	fmt.Println("Websocket server stopped on", wss.addr)
 
 	return nil
 }